@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxActivitiesWindow is the longest range the activities endpoint accepts in one request.
+const maxActivitiesWindow = 31 * 24 * time.Hour
+
+type ActivityType string
+
+const (
+	ActivityTypeTrades               ActivityType = "Trades"
+	ActivityTypeDividends            ActivityType = "Dividends"
+	ActivityTypeDividendReinvestment ActivityType = "Dividend reinvestment"
+	ActivityTypeDeposits             ActivityType = "Deposits"
+	ActivityTypeWithdrawals          ActivityType = "Withdrawals"
+	ActivityTypeFeesAndRebates       ActivityType = "Fees and rebates"
+	ActivityTypeInterest             ActivityType = "Interest"
+	ActivityTypeTransfers            ActivityType = "Transfers"
+	ActivityTypeCorporateActions     ActivityType = "Corporate actions"
+	ActivityTypeFXConversion         ActivityType = "FX conversion"
+	ActivityTypeOther                ActivityType = "Other"
+)
+
+type Activity struct {
+	TradeDate       Time         `json:"tradeDate"`
+	TransactionDate Time         `json:"transactionDate"`
+	SettlementDate  Time         `json:"settlementDate"`
+	Action          string       `json:"action"`
+	Symbol          string       `json:"symbol"`
+	SymbolId        int          `json:"symbolId"`
+	Description     string       `json:"description"`
+	Currency        string       `json:"currency"`
+	Quantity        float64      `json:"quantity"`
+	Price           float64      `json:"price"`
+	GrossAmount     float64      `json:"grossAmount"`
+	Commission      float64      `json:"commission"`
+	NetAmount       float64      `json:"netAmount"`
+	Type            ActivityType `json:"type"`
+}
+
+// key identifies an activity across windows. Activities have no id.
+func (a Activity) key() string {
+	return fmt.Sprintf("%d|%d|%d|%v|%v|%d|%v|%v|%v|%v|%v|%v|%v|%v",
+		a.TradeDate.UnixNano(), a.TransactionDate.UnixNano(), a.SettlementDate.UnixNano(),
+		a.Action, a.Symbol, a.SymbolId, a.Description, a.Currency, a.Quantity, a.Price,
+		a.GrossAmount, a.Commission, a.NetAmount, a.Type)
+}
+
+type ActivitiesResponse struct {
+	Activities []Activity `json:"activities"`
+}
+
+// Activities lists the activities of account id between start and end,
+// walking the range in windows of at most 31 days. Activities reported by two
+// windows are only returned once.
+func (c *Client) Activities(ctx context.Context, id string, start, end time.Time) ([]Activity, error) {
+	var activities []Activity
+	var dedup windowDedup
+	endpoint := fmt.Sprintf("v1/accounts/%v/activities", id)
+	for _, w := range splitRange(start, end, maxActivitiesWindow) {
+		result := &ActivitiesResponse{}
+		params := map[string]string{
+			"startTime": formatTime(w.Start),
+			"endTime":   formatTime(w.End),
+		}
+		if err := c.getJSON(ctx, endpoint, params, result); err != nil {
+			return nil, err
+		}
+		dedup.next()
+		for _, a := range result.Activities {
+			if dedup.keep(a.key()) {
+				activities = append(activities, a)
+			}
+		}
+	}
+	return activities, nil
+}
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+func TestActivitiesWindows(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	fee := func(at time.Time, description string) api.Activity {
+		return api.Activity{TransactionDate: api.Time{Time: at}, Description: description, Currency: "CAD",
+			NetAmount: -9.95, Type: api.ActivityTypeFeesAndRebates}
+	}
+	server.UpdateFixtures(func(f *questradetest.Fixtures) {
+		f.Activities["11111111"] = []api.Activity{
+			fee(day(0), "first"),
+			// Two equal fees on the boundary of the first and second
+			// 31-day windows, and one on the boundary of the next ones.
+			fee(day(31), "boundary"),
+			fee(day(31), "boundary"),
+			fee(day(62), "boundary"),
+			fee(day(80), "last"),
+			// Outside the range.
+			fee(day(94), "later"),
+		}
+	})
+	c := newTestClient(t, server)
+
+	// 93 days take three windows of at most 31 days; the server rejects longer ones.
+	activities, err := c.Activities(context.Background(), "11111111", day(0), day(93))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range activities {
+		got = append(got, a.TransactionDate.Format("2006-01-02")+" "+a.Description)
+	}
+	want := []string{"2025-01-01 first", "2025-02-01 boundary", "2025-02-01 boundary", "2025-03-04 boundary", "2025-03-22 last"}
+	if len(got) != len(want) {
+		t.Fatalf("got activities %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got activities %v, want %v", got, want)
+			break
+		}
+	}
+	if n := server.Count("v1/accounts/11111111/activities"); n != 3 {
+		t.Errorf("sent %v requests, want 3", n)
+	}
+}
@@ -1,12 +1,11 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
-
-	"github.com/levigross/grequests"
+	"time"
 )
 
 type Session struct {
@@ -15,61 +14,114 @@ type Session struct {
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
+	// ExpiresAt is computed from ExpiresIn when the session is redeemed. The
+	// zero time, also read from sessions saved without it, means the expiry
+	// is unknown: the session is not considered expired and is refreshed
+	// when loaded from a TokenStore.
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 func makeRedeemResponse(data string) (*Session, error) {
 	resp := &Session{}
 	err := json.Unmarshal([]byte(data), resp)
 	if err != nil {
-		log.Fatalln("Error making Session: ", data)
-		return resp, err
+		return nil, fmt.Errorf("error making Session: %w", err)
 	}
 	strings.Replace(resp.ApiServer, "\\/", "/", -1)
+	resp.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
 	return resp, nil
 }
 
-func Redeem(refreshToken string) (*Session, error) {
-	params := &grequests.RequestOptions{
-		Params: map[string]string{
-			"grant_type":    "refresh_token",
-			"refresh_token": refreshToken}}
-
-	resp, err := grequests.Post("https://login.questrade.com/oauth2/token", params)
-	if err != nil {
-		log.Fatalln("Unable to make request: ", err)
-		log.Fatalln("Received status code", resp.StatusCode)
-		log.Fatalln(resp.String())
-		return nil, err
-	}
+// Redeem exchanges refreshToken for a new session using the default client.
+func Redeem(ctx context.Context, refreshToken string) (*Session, error) {
+	return NewClient(nil).Redeem(ctx, refreshToken)
+}
+
+type AccountType string
+
+const (
+	AccountTypeCash   AccountType = "Cash"
+	AccountTypeMargin AccountType = "Margin"
+	AccountTypeTFSA   AccountType = "TFSA"
+	AccountTypeRRSP   AccountType = "RRSP"
+	AccountTypeSRRSP  AccountType = "SRRSP"
+	AccountTypeLRRSP  AccountType = "LRRSP"
+	AccountTypeLIRA   AccountType = "LIRA"
+	AccountTypeLIF    AccountType = "LIF"
+	AccountTypeRIF    AccountType = "RIF"
+	AccountTypeSRIF   AccountType = "SRIF"
+	AccountTypeLRIF   AccountType = "LRIF"
+	AccountTypeRRIF   AccountType = "RRIF"
+	AccountTypePRIF   AccountType = "PRIF"
+	AccountTypeRESP   AccountType = "RESP"
+	AccountTypeFRESP  AccountType = "FRESP"
+	AccountTypeFHSA   AccountType = "FHSA"
+)
 
-	if resp.StatusCode == 200 {
-		//log.Println(resp.String())
-		result, err := makeRedeemResponse(resp.String())
-		return result, err
-	}
-	log.Println(resp.String())
-	log.Fatalln("Unable to Redeem refresh token. Status Code is not 200", resp.StatusCode)
-	return nil, err
+// Registered reports whether the account is a tax-sheltered account.
+func (t AccountType) Registered() bool {
+	return t != AccountTypeCash && t != AccountTypeMargin && t != ""
 }
 
-type ApiError struct{}
+type AccountStatus string
 
-func (e *ApiError) Error() string {
-	return "Request failed"
-}
+const (
+	AccountStatusActive          AccountStatus = "Active"
+	AccountStatusSuspendedClosed AccountStatus = "Suspended (Closed)"
+	AccountStatusSuspendedView   AccountStatus = "Suspended (View Only)"
+	AccountStatusLiquidateOnly   AccountStatus = "Liquidate Only"
+	AccountStatusClosed          AccountStatus = "Closed"
+)
+
+// Closed reports whether the account no longer holds anything.
+func (s AccountStatus) Closed() bool {
+	return s == AccountStatusClosed || s == AccountStatusSuspendedClosed
+}
+
+type ClientAccountType string
+
+const (
+	ClientAccountTypeIndividual         ClientAccountType = "Individual"
+	ClientAccountTypeJoint              ClientAccountType = "Joint"
+	ClientAccountTypeInformalTrust      ClientAccountType = "Informal Trust"
+	ClientAccountTypeCorporation        ClientAccountType = "Corporation"
+	ClientAccountTypeInvestmentClub     ClientAccountType = "Investment Club"
+	ClientAccountTypeFormalTrust        ClientAccountType = "Formal Trust"
+	ClientAccountTypePartnership        ClientAccountType = "Partnership"
+	ClientAccountTypeSoleProprietorship ClientAccountType = "Sole Proprietorship"
+	ClientAccountTypeFamily             ClientAccountType = "Family"
+	ClientAccountTypeJointInformalTrust ClientAccountType = "Joint and Informal Trust"
+	ClientAccountTypeInstitution        ClientAccountType = "Institution"
+)
 
 type Account struct {
-	Number string `json:"number"`
+	Type              AccountType       `json:"type"`
+	Number            string            `json:"number"`
+	Status            AccountStatus     `json:"status"`
+	IsPrimary         bool              `json:"isPrimary"`
+	IsBilling         bool              `json:"isBilling"`
+	ClientAccountType ClientAccountType `json:"clientAccountType"`
 }
 
 type AccountsResponse struct {
 	Accounts []Account `json:"accounts"`
+	UserId   int       `json:"userId"`
 }
 
 type Position struct {
 	Symbol             string  `json:"symbol"`
+	SymbolId           int     `json:"symbolId"`
+	OpenQuantity       float64 `json:"openQuantity"`
+	ClosedQuantity     float64 `json:"closedQuantity"`
 	CurrentMarketValue float64 `json:"currentMarketValue"`
-	CurrentPrice       float64 `json:"currentPrint"`
+	CurrentPrice       float64 `json:"currentPrice"`
+	AverageEntryPrice  float64 `json:"averageEntryPrice"`
+	TotalCost          float64 `json:"totalCost"`
+	OpenPnl            float64 `json:"openPnl"`
+	ClosedPnl          float64 `json:"closedPnl"`
+	DayPnl             float64 `json:"dayPnl"`
+	IsRealTime         bool    `json:"isRealTime"`
+	IsUnderReorg       bool    `json:"isUnderReorg"`
 }
 
 type PositionsResponse struct {
@@ -77,104 +129,98 @@ type PositionsResponse struct {
 }
 
 type Balance struct {
-	Currency string  `json:"currency"`
-	Cash     float64 `json:"cash"`
-}
-
+	Currency          string  `json:"currency"`
+	Cash              float64 `json:"cash"`
+	MarketValue       float64 `json:"marketValue"`
+	TotalEquity       float64 `json:"totalEquity"`
+	BuyingPower       float64 `json:"buyingPower"`
+	MaintenanceExcess float64 `json:"maintenanceExcess"`
+	IsRealTime        bool    `json:"isRealTime"`
+}
+
+// BalancesResponse holds the current balances and the start-of-day (sod)
+// balances of an account. Per-currency balances only count what is held in
+// that currency; combined balances convert everything into each currency.
 type BalancesResponse struct {
-	PerCurrencyBalances []Balance `json:"perCurrencyBalances"`
+	PerCurrencyBalances    []Balance `json:"perCurrencyBalances"`
+	CombinedBalances       []Balance `json:"combinedBalances"`
+	SodPerCurrencyBalances []Balance `json:"sodPerCurrencyBalances"`
+	SodCombinedBalances    []Balance `json:"sodCombinedBalances"`
 }
 
-func get(session *Session, endpoint string) (*grequests.Response, error) {
-	params := &grequests.RequestOptions{
-		Headers: map[string]string{"Authorization": "Bearer " + session.AccessToken},
-	}
-	url := session.ApiServer + endpoint
-	log.Println(url)
-	resp, err := grequests.Get(session.ApiServer+endpoint, params)
-	return resp, err
-}
-
-func Accounts(session *Session) (*AccountsResponse, error) {
-	result := &AccountsResponse{}
-	endpoint := "v1/accounts"
-	retried := false
-Retry:
-	resp, err := get(session, endpoint)
-	if err != nil {
-		log.Fatalf("%v failed: %v\n", endpoint, err)
-		return nil, err
-	}
-	if resp.StatusCode == 401 {
-		newSession, err := Redeem(session.RefreshToken)
-		*session = *newSession
-		if err != nil {
-			log.Println(err)
-			log.Fatalf("Unable to redeem refresh token %v", err)
-		}
-		if !retried {
-			retried = true
-			goto Retry
+// balanceIn picks the balance in currency out of balances.
+func balanceIn(balances []Balance, currency string) (Balance, bool) {
+	for _, b := range balances {
+		if b.Currency == currency {
+			return b, true
 		}
-
 	}
-	if resp.StatusCode != 200 {
-		log.Println(resp.String())
-		log.Fatalf("%v status code is %d", endpoint, resp.StatusCode)
-		return nil, &ApiError{}
+	return Balance{}, false
+}
+
+// PerCurrency returns the balance held in currency.
+func (r *BalancesResponse) PerCurrency(currency string) (Balance, bool) {
+	return balanceIn(r.PerCurrencyBalances, currency)
+}
+
+// Combined returns the balance of the whole account expressed in currency.
+func (r *BalancesResponse) Combined(currency string) (Balance, bool) {
+	return balanceIn(r.CombinedBalances, currency)
+}
+
+// SodCombined returns the start-of-day balance of the whole account expressed in currency.
+func (r *BalancesResponse) SodCombined(currency string) (Balance, bool) {
+	return balanceIn(r.SodCombinedBalances, currency)
+}
+
+// EquityChange returns how much the total equity of the account, expressed
+// in currency, changed since the start of the day.
+func (r *BalancesResponse) EquityChange(currency string) (float64, bool) {
+	now, ok := r.Combined(currency)
+	if !ok {
+		return 0, false
 	}
-	err = json.Unmarshal([]byte(resp.String()), result)
-	if err != nil {
-		log.Fatalln("Failed to parse result.", err)
-		return nil, err
+	sod, ok := r.SodCombined(currency)
+	if !ok {
+		return 0, false
 	}
-	return result, nil
+	return now.TotalEquity - sod.TotalEquity, true
+}
+
+// Accounts lists the accounts of session's user. session is updated in place
+// if the refresh token had to be redeemed.
+func Accounts(ctx context.Context, session *Session) (*AccountsResponse, error) {
+	return NewClient(session).Accounts(ctx)
 }
 
-func CheckStatus(statusCode int) {
+// CheckStatus returns an *ApiError if statusCode is not 200.
+func CheckStatus(statusCode int) error {
 	if statusCode != 200 {
-		log.Fatalf("status code is %d", statusCode)
+		return &ApiError{StatusCode: statusCode, sentinel: sentinelForStatus(statusCode)}
 	}
+	return nil
 }
 
-func CheckError(err error, msg string) {
+// CheckError wraps err with msg. It returns nil if err is nil.
+func CheckError(err error, msg string) error {
 	if err != nil {
-		log.Fatalf("%v %v", msg, err)
+		return fmt.Errorf("%v %w", msg, err)
 	}
+	return nil
 }
 
-func CheckHttpResponse(err error, msg string) {
+// CheckHttpResponse wraps a transport error returned for the request to msg.
+func CheckHttpResponse(err error, msg string) error {
 	if err != nil {
-		log.Fatalf("%v %v\n", msg, err)
+		return fmt.Errorf("%v failed: %w", msg, err)
 	}
+	return nil
 }
 
-func Positions(session *Session, id string) (*PositionsResponse, error) {
-	result := &PositionsResponse{}
-	endpoint := fmt.Sprintf("v1/accounts/%v/positions", id)
-	resp, err := get(session, endpoint)
-
-	CheckHttpResponse(err, endpoint)
-
-	CheckStatus(resp.StatusCode)
-
-	err = json.Unmarshal([]byte(resp.String()), result)
-	CheckError(err, "Failed to parse result.")
-
-	return result, nil
+func Positions(ctx context.Context, session *Session, id string) (*PositionsResponse, error) {
+	return NewClient(session).Positions(ctx, id)
 }
 
-func Balances(session *Session, id string) (*BalancesResponse, error) {
-	result := &BalancesResponse{}
-	endpoint := fmt.Sprintf("v1/accounts/%v/balances", id)
-	resp, err := get(session, endpoint)
-
-	CheckHttpResponse(err, endpoint)
-
-	CheckStatus(resp.StatusCode)
-
-	err = json.Unmarshal([]byte(resp.String()), result)
-	CheckError(err, "Failed to parse result.")
-
-	return result, nil
+func Balances(ctx context.Context, session *Session, id string) (*BalancesResponse, error) {
+	return NewClient(session).Balances(ctx, id)
 }
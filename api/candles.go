@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxCandlesPerRequest is the most candles the server returns for one request.
+const maxCandlesPerRequest = 2000
+
+type Interval string
+
+const (
+	OneMinute      Interval = "OneMinute"
+	TwoMinutes     Interval = "TwoMinutes"
+	ThreeMinutes   Interval = "ThreeMinutes"
+	FourMinutes    Interval = "FourMinutes"
+	FiveMinutes    Interval = "FiveMinutes"
+	TenMinutes     Interval = "TenMinutes"
+	FifteenMinutes Interval = "FifteenMinutes"
+	TwentyMinutes  Interval = "TwentyMinutes"
+	HalfHour       Interval = "HalfHour"
+	OneHour        Interval = "OneHour"
+	TwoHours       Interval = "TwoHours"
+	FourHours      Interval = "FourHours"
+	OneDay         Interval = "OneDay"
+	OneWeek        Interval = "OneWeek"
+	OneMonth       Interval = "OneMonth"
+	OneYear        Interval = "OneYear"
+)
+
+var intervalDurations = map[Interval]time.Duration{
+	OneMinute:      time.Minute,
+	TwoMinutes:     2 * time.Minute,
+	ThreeMinutes:   3 * time.Minute,
+	FourMinutes:    4 * time.Minute,
+	FiveMinutes:    5 * time.Minute,
+	TenMinutes:     10 * time.Minute,
+	FifteenMinutes: 15 * time.Minute,
+	TwentyMinutes:  20 * time.Minute,
+	HalfHour:       30 * time.Minute,
+	OneHour:        time.Hour,
+	TwoHours:       2 * time.Hour,
+	FourHours:      4 * time.Hour,
+	OneDay:         24 * time.Hour,
+	OneWeek:        7 * 24 * time.Hour,
+	// Calendar intervals use their longest length so a window never holds
+	// more than maxCandlesPerRequest candles.
+	OneMonth: 31 * 24 * time.Hour,
+	OneYear:  366 * 24 * time.Hour,
+}
+
+// Duration returns the length of one candle, or 0 for an unknown interval.
+func (i Interval) Duration() time.Duration {
+	return intervalDurations[i]
+}
+
+type Candle struct {
+	Start  Time    `json:"start"`
+	End    Time    `json:"end"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Open   float64 `json:"open"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+	VWAP   float64 `json:"VWAP"`
+}
+
+type CandlesResponse struct {
+	Candles []Candle `json:"candles"`
+}
+
+// CandleSeries is the cached candles of one symbol and interval, covering [From, To).
+type CandleSeries struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Candles []Candle  `json:"candles"`
+}
+
+// CandleCache stores candles between calls to Client.Candles. Load returns
+// nil and no error when nothing is cached yet.
+type CandleCache interface {
+	Load(id int, interval Interval) (*CandleSeries, error)
+	Save(id int, interval Interval, series *CandleSeries) error
+}
+
+// FileCandleCache keeps one JSON file per symbol and interval in Dir. A file
+// that can not be decoded is treated as not cached and replaced on the next
+// Save.
+type FileCandleCache struct {
+	Dir string
+}
+
+func (fc *FileCandleCache) path(id int, interval Interval) string {
+	return filepath.Join(fc.Dir, fmt.Sprintf("%d-%s.json", id, interval))
+}
+
+func (fc *FileCandleCache) Load(id int, interval Interval) (*CandleSeries, error) {
+	data, err := ioutil.ReadFile(fc.path(id, interval))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	series := &CandleSeries{}
+	if err = json.Unmarshal(data, series); err != nil {
+		log.Printf("Ignoring damaged candle cache %v: %v\n", fc.path(id, interval), err)
+		return nil, nil
+	}
+	return series, nil
+}
+
+func (fc *FileCandleCache) Save(id int, interval Interval, series *CandleSeries) error {
+	data, err := json.Marshal(series)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(fc.Dir, 0755); err != nil {
+		return err
+	}
+	// Write a temporary file and rename it, so an interrupted Save leaves
+	// the previous file intact.
+	path := fc.path(id, interval)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// fetchCandles requests [start, end) in windows of at most maxCandlesPerRequest candles.
+func (c *Client) fetchCandles(ctx context.Context, id int, start, end time.Time, interval Interval) ([]Candle, error) {
+	size := interval.Duration()
+	if size == 0 {
+		return nil, fmt.Errorf("unknown candle interval %q", interval)
+	}
+	var candles []Candle
+	endpoint := fmt.Sprintf("v1/markets/candles/%d", id)
+	for _, w := range splitRange(start, end, size*maxCandlesPerRequest) {
+		result := &CandlesResponse{}
+		params := map[string]string{
+			"startTime": formatTime(w.Start),
+			"endTime":   formatTime(w.End),
+			"interval":  string(interval),
+		}
+		if err := c.getJSON(ctx, endpoint, params, result); err != nil {
+			return nil, err
+		}
+		candles = append(candles, result.Candles...)
+	}
+	return candles, nil
+}
+
+// mergeCandles combines candles keyed on their start time; later candles
+// replace earlier ones, so a refetched partial candle wins.
+func mergeCandles(lists ...[]Candle) []Candle {
+	byStart := map[int64]Candle{}
+	for _, list := range lists {
+		for _, candle := range list {
+			byStart[candle.Start.UnixNano()] = candle
+		}
+	}
+	merged := make([]Candle, 0, len(byStart))
+	for _, candle := range byStart {
+		merged = append(merged, candle)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Start.Before(merged[j].Start.Time) })
+	return merged
+}
+
+// Candles returns the candles of symbol id between start and end. Ranges
+// longer than one request allows are split. When the client has a
+// CandleCache only the part of the range not cached yet is fetched, plus the
+// last cached candle which may have been incomplete.
+func (c *Client) Candles(ctx context.Context, id int, start, end time.Time, interval Interval) ([]Candle, error) {
+	if c.CandleCache == nil {
+		return c.fetchCandles(ctx, id, start, end, interval)
+	}
+	series, err := c.CandleCache.Load(id, interval)
+	if err != nil {
+		return nil, err
+	}
+	if series == nil {
+		series = &CandleSeries{From: start, To: start}
+	}
+	covered := end
+	if now := time.Now(); covered.After(now) {
+		covered = now
+	}
+
+	fetched := [][]Candle{series.Candles}
+	if start.Before(series.From) {
+		head, err := c.fetchCandles(ctx, id, start, series.From, interval)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, head)
+		series.From = start
+	}
+	if covered.After(series.To) {
+		from := series.To
+		if n := len(series.Candles); n > 0 && series.Candles[n-1].Start.Before(from) {
+			from = series.Candles[n-1].Start.Time
+		}
+		tail, err := c.fetchCandles(ctx, id, from, end, interval)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, tail)
+		series.To = covered
+	}
+	if len(fetched) > 1 {
+		series.Candles = mergeCandles(fetched...)
+		if err = c.CandleCache.Save(id, interval, series); err != nil {
+			return nil, err
+		}
+	}
+
+	var candles []Candle
+	for _, candle := range series.Candles {
+		if !candle.Start.Before(start) && candle.Start.Before(end) {
+			candles = append(candles, candle)
+		}
+	}
+	return candles, nil
+}
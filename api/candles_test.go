@@ -0,0 +1,128 @@
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+const candlesPath = "v1/markets/candles/9292"
+
+// minuteServer serves n one-minute candles of VFV.TO starting at base, with
+// the minute number as their close.
+func minuteServer(base time.Time, n int) *questradetest.Server {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	server.UpdateFixtures(func(f *questradetest.Fixtures) {
+		f.Candles[9292] = nil
+		for i := 0; i < n; i++ {
+			start := base.Add(time.Duration(i) * time.Minute)
+			f.Candles[9292] = append(f.Candles[9292], api.Candle{
+				Start: api.Time{Time: start}, End: api.Time{Time: start.Add(time.Minute)}, Close: float64(i)})
+		}
+	})
+	return server
+}
+
+// checkMinutes checks that candles are the minutes from first on, in order.
+func checkMinutes(t *testing.T, candles []api.Candle, first, n int) {
+	t.Helper()
+	if len(candles) != n {
+		t.Fatalf("got %v candles, want %v", len(candles), n)
+	}
+	for i, c := range candles {
+		if c.Close != float64(first+i) {
+			t.Fatalf("candle %v has close %v, want %v", i, c.Close, first+i)
+		}
+	}
+}
+
+func TestCandlesSplit(t *testing.T) {
+	base := time.Now().Truncate(time.Minute).Add(-3000 * time.Minute)
+	server := minuteServer(base, 2500)
+	defer server.Close()
+	c := newTestClient(t, server)
+
+	// The server returns at most 2000 candles, so the range takes two requests.
+	candles, err := c.Candles(context.Background(), 9292, base, base.Add(2500*time.Minute), api.OneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMinutes(t, candles, 0, 2500)
+	if n := server.Count(candlesPath); n != 2 {
+		t.Errorf("sent %v requests, want 2", n)
+	}
+}
+
+func TestCandlesCache(t *testing.T) {
+	ctx := context.Background()
+	base := time.Now().Truncate(time.Minute).Add(-3000 * time.Minute)
+	server := minuteServer(base, 2500)
+	defer server.Close()
+	c := newTestClient(t, server)
+	c.CandleCache = &api.FileCandleCache{Dir: t.TempDir()}
+
+	candles, err := c.Candles(ctx, 9292, base, base.Add(2100*time.Minute), api.OneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMinutes(t, candles, 0, 2100)
+	if n := server.Count(candlesPath); n != 2 {
+		t.Errorf("sent %v requests for the first range, want 2", n)
+	}
+
+	// A range inside the cached one is answered from the cache.
+	candles, err = c.Candles(ctx, 9292, base.Add(100*time.Minute), base.Add(200*time.Minute), api.OneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMinutes(t, candles, 100, 100)
+	if n := server.Count(candlesPath); n != 2 {
+		t.Errorf("sent %v requests in total after a cache hit, want 2", n)
+	}
+
+	// A longer range only fetches the tail, starting with the last cached
+	// candle, which replaces the cached one in case it was incomplete.
+	server.UpdateFixtures(func(f *questradetest.Fixtures) {
+		f.Candles[9292][2099].High = 1
+	})
+	candles, err = c.Candles(ctx, 9292, base, base.Add(2500*time.Minute), api.OneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMinutes(t, candles, 0, 2500)
+	if n := server.Count(candlesPath); n != 3 {
+		t.Errorf("sent %v requests in total after extending the range, want 3", n)
+	}
+	if candles[2099].High != 1 {
+		t.Errorf("last cached candle was not refetched: %+v", candles[2099])
+	}
+}
+
+func TestCandlesCorruptCache(t *testing.T) {
+	base := time.Now().Truncate(time.Minute).Add(-3000 * time.Minute)
+	server := minuteServer(base, 100)
+	defer server.Close()
+	c := newTestClient(t, server)
+	dir := t.TempDir()
+	c.CandleCache = &api.FileCandleCache{Dir: dir}
+	path := filepath.Join(dir, "9292-OneMinute.json")
+	if err := ioutil.WriteFile(path, []byte(`{"from":"2024-01-01T00:00:00Z","candles":[{"st`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The damaged file is ignored and replaced by the fetched candles.
+	candles, err := c.Candles(context.Background(), 9292, base, base.Add(100*time.Minute), api.OneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMinutes(t, candles, 0, 100)
+	series, err := c.CandleCache.Load(9292, api.OneMinute)
+	if err != nil || series == nil || len(series.Candles) != 100 {
+		t.Fatalf("cache holds %+v, %v after the refetch, want the 100 candles", series, err)
+	}
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/levigross/grequests"
+)
+
+// DefaultLoginURL is the Questrade OAuth token endpoint used to redeem refresh tokens.
+const DefaultLoginURL = "https://login.questrade.com/oauth2/token"
+
+// ClientOptions holds settings applied to every request made by a Client.
+type ClientOptions struct {
+	// ApiServer overrides the api_server returned with the session, e.g. to
+	// point the client at a local stub server. It must end with a slash.
+	ApiServer string
+	// UserAgent is sent with every request when set.
+	UserAgent string
+	// Headers are added to every request.
+	Headers map[string]string
+	// FailOnRateLimit makes requests return ErrRateLimited instead of
+	// blocking until the rate-limit budget resets.
+	FailOnRateLimit bool
+	// SkipOrderImpact places orders without previewing their impact first.
+	SkipOrderImpact bool
+	// Retry overrides DefaultRetryPolicy for transient failures.
+	Retry *RetryPolicy
+	// ConfirmOrder is called with an order and its previewed impact before the
+	// order is sent. Returning an error cancels the order.
+	ConfirmOrder func(order interface{}, impact *OrderImpact) error
+}
+
+// Client talks to the Questrade API on behalf of a single Session.
+type Client struct {
+	Session    *Session
+	LoginURL   string
+	HTTPClient *http.Client
+	Options    ClientOptions
+	// TokenStore, when set, receives the session every time it is refreshed.
+	TokenStore TokenStore
+	// CandleCache, when set, keeps candles fetched by Candles between calls.
+	CandleCache CandleCache
+	// mu guards Session while it is being refreshed.
+	mu        sync.Mutex
+	limiter   rateLimiter
+	symbols   symbolCache
+	mutations mutationLog
+}
+
+// NewClient returns a Client for session using the default login URL and http.Client.
+// session may be nil if the client is only going to be used to Redeem a refresh token.
+func NewClient(session *Session) *Client {
+	return &Client{
+		Session:    session,
+		LoginURL:   DefaultLoginURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (c *Client) requestOptions() *grequests.RequestOptions {
+	ro := &grequests.RequestOptions{
+		HTTPClient: c.HTTPClient,
+		UserAgent:  c.Options.UserAgent,
+		Headers:    map[string]string{},
+	}
+	for k, v := range c.Options.Headers {
+		ro.Headers[k] = v
+	}
+	return ro
+}
+
+func (c *Client) apiServer() string {
+	if c.Options.ApiServer != "" {
+		return c.Options.ApiServer
+	}
+	return c.Session.ApiServer
+}
+
+// Redeem exchanges refreshToken for a new session. The client's Session is
+// updated in place so that anyone sharing the pointer sees the new tokens,
+// and saved to the TokenStore if there is one.
+func (c *Client) Redeem(ctx context.Context, refreshToken string) (*Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.redeem(ctx, refreshToken)
+}
+
+func (c *Client) redeem(ctx context.Context, refreshToken string) (*Session, error) {
+	return c.requestToken(ctx, "redeem refresh token", map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken})
+}
+
+// requestToken posts params to the login server and makes the session it
+// answers with the client's session. action describes the request in errors.
+func (c *Client) requestToken(ctx context.Context, action string, params map[string]string) (*Session, error) {
+	ro := c.requestOptions()
+	ro.Params = params
+	ro.Context = ctx
+
+	resp, err := grequests.Post(c.LoginURL, ro)
+	if err != nil {
+		return nil, fmt.Errorf("unable to %v: %w", action, err)
+	}
+
+	if resp.StatusCode != 200 {
+		apiErr := newApiError(c.LoginURL, resp)
+		// The login server answers 400 for an expired or already used refresh token or code.
+		if resp.StatusCode == 400 {
+			apiErr.sentinel = ErrUnauthorized
+		}
+		return nil, apiErr
+	}
+
+	session, err := makeRedeemResponse(resp.String())
+	if err != nil {
+		return nil, err
+	}
+	return c.setSession(session)
+}
+
+// setSession replaces the client's session and saves it to the TokenStore.
+func (c *Client) setSession(session *Session) (*Session, error) {
+	if c.Session == nil {
+		c.Session = session
+	} else {
+		*c.Session = *session
+	}
+	if c.TokenStore != nil {
+		if err := c.TokenStore.Save(c.Session); err != nil {
+			return nil, fmt.Errorf("unable to save session: %w", err)
+		}
+	}
+	return c.Session, nil
+}
+
+// do sends an authenticated request to endpoint. The access token is
+// refreshed before it expires, and a 401 response is retried once with a
+// freshly redeemed token. Requests wait for the rate-limit budget of their
+// category and a 429 response is retried after the budget resets. Transient
+// failures are retried according to the client's RetryPolicy; idempotent
+// tells whether the request can be repeated without side effects.
+func (c *Client) do(ctx context.Context, method, endpoint string, ro *grequests.RequestOptions, idempotent bool) (*grequests.Response, error) {
+	category := categoryFor(endpoint)
+	policy := c.retryPolicy()
+	retried := false
+	throttled := 0
+	attempt := 1
+	for {
+		if wait := c.limiter.reserve(category); wait > 0 {
+			if c.Options.FailOnRateLimit {
+				return nil, &ApiError{StatusCode: 429, Endpoint: endpoint, sentinel: ErrRateLimited}
+			}
+			log.Printf("%v budget spent, waiting %v\n", category, wait)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ro.Headers["Authorization"] = "Bearer " + token
+		ro.Context = ctx
+		url := c.apiServer() + endpoint
+		log.Println(method, url)
+		resp, err := grequests.Req(method, url, ro)
+		if err != nil {
+			if attempt < policy.MaxAttempts && policy.retries(idempotent) && retryableError(ctx, err) {
+				if err = c.backoff(ctx, policy, attempt, 0, err); err != nil {
+					return nil, err
+				}
+				attempt++
+				continue
+			}
+			return nil, CheckHttpResponse(err, endpoint)
+		}
+		c.limiter.update(category, resp.Header)
+		if resp.StatusCode == 401 && !retried {
+			retried = true
+			resp.Close()
+			if err = c.refresh(ctx, token); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode == 429 {
+			c.limiter.exhaust(category, resp.Header)
+			if throttled < maxRateLimitRetries && !c.Options.FailOnRateLimit {
+				throttled++
+				resp.Close()
+				continue
+			}
+		}
+		if attempt < policy.MaxAttempts && policy.retries(idempotent) && policy.retryableStatus(resp.StatusCode) {
+			resp.Close()
+			if err = c.backoff(ctx, policy, attempt, retryAfter(resp.Header), fmt.Errorf("status %v", resp.StatusCode)); err != nil {
+				return nil, err
+			}
+			attempt++
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// backoff waits before retry number attempt, at least as long as the server
+// asked for with minimum.
+func (c *Client) backoff(ctx context.Context, policy *RetryPolicy, attempt int, minimum time.Duration, cause error) error {
+	wait := policy.backoff(attempt)
+	if wait < minimum {
+		wait = minimum
+	}
+	log.Printf("Attempt %d failed: %v, retrying in %v\n", attempt, cause, wait)
+	return sleep(ctx, wait)
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getJSON fetches endpoint with the query params and decodes the response into result.
+func (c *Client) getJSON(ctx context.Context, endpoint string, params map[string]string, result interface{}) error {
+	ro := c.requestOptions()
+	ro.Params = params
+	resp, err := c.do(ctx, "GET", endpoint, ro, true)
+	if err != nil {
+		return err
+	}
+	return decode(endpoint, resp, result)
+}
+
+// postJSON posts body as JSON to endpoint and decodes the response into result.
+// It is meant for requests that change orders, which are only retried if the
+// RetryPolicy allows mutations.
+func (c *Client) postJSON(ctx context.Context, endpoint string, body interface{}, result interface{}) error {
+	return c.sendJSON(ctx, "POST", endpoint, body, result, false)
+}
+
+// queryJSON posts body as JSON to an endpoint without side effects, such as
+// an order impact preview, and decodes the response into result.
+func (c *Client) queryJSON(ctx context.Context, endpoint string, body interface{}, result interface{}) error {
+	return c.sendJSON(ctx, "POST", endpoint, body, result, true)
+}
+
+// sendJSON sends body, if any, as JSON to endpoint and decodes the response into result.
+func (c *Client) sendJSON(ctx context.Context, method, endpoint string, body interface{}, result interface{}, idempotent bool) error {
+	ro := c.requestOptions()
+	ro.JSON = body
+	resp, err := c.do(ctx, method, endpoint, ro, idempotent)
+	if err != nil {
+		return err
+	}
+	return decode(endpoint, resp, result)
+}
+
+// decode checks the status of resp and unmarshals its body into result.
+func decode(endpoint string, resp *grequests.Response, result interface{}) error {
+	if resp.StatusCode != 200 {
+		return newApiError(endpoint, resp)
+	}
+	err := json.Unmarshal(resp.Bytes(), result)
+	return CheckError(err, endpoint+": failed to parse result:")
+}
+
+// Accounts lists the accounts of the session's user.
+func (c *Client) Accounts(ctx context.Context) (*AccountsResponse, error) {
+	result := &AccountsResponse{}
+	if err := c.getJSON(ctx, "v1/accounts", nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Positions lists the positions held in account id.
+func (c *Client) Positions(ctx context.Context, id string) (*PositionsResponse, error) {
+	result := &PositionsResponse{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/positions", id)
+	if err := c.getJSON(ctx, endpoint, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Balances returns the balances of account id.
+func (c *Client) Balances(ctx context.Context, id string) (*BalancesResponse, error) {
+	result := &BalancesResponse{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/balances", id)
+	if err := c.getJSON(ctx, endpoint, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
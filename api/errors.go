@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/levigross/grequests"
+)
+
+var (
+	// ErrUnauthorized is returned when the access token is rejected or the
+	// refresh token can not be redeemed.
+	ErrUnauthorized = errors.New("questrade: unauthorized")
+	// ErrRateLimited is returned when the server answers 429 Too Many Requests.
+	ErrRateLimited = errors.New("questrade: rate limit exceeded")
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("questrade: not found")
+)
+
+// ApiError describes a non-200 response from Questrade. Code and Message are
+// taken from the JSON error body when the server sends one.
+// Use errors.Is with ErrUnauthorized, ErrRateLimited or ErrNotFound to
+// classify it.
+type ApiError struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+	Endpoint   string `json:"-"`
+	RequestID  string `json:"-"`
+	sentinel   error
+}
+
+func (e *ApiError) Error() string {
+	msg := fmt.Sprintf("%v: status code %d", e.Endpoint, e.StatusCode)
+	if e.Code != 0 || e.Message != "" {
+		msg = fmt.Sprintf("%v: code %d: %v", msg, e.Code, e.Message)
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%v (request id %v)", msg, e.RequestID)
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error matching the status code, if any.
+func (e *ApiError) Unwrap() error {
+	return e.sentinel
+}
+
+func sentinelForStatus(statusCode int) error {
+	switch statusCode {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return ErrNotFound
+	case 429:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// newApiError builds an ApiError from a non-200 response to endpoint.
+func newApiError(endpoint string, resp *grequests.Response) *ApiError {
+	e := &ApiError{}
+	// The body is best effort: error pages from proxies are not JSON.
+	_ = json.Unmarshal(resp.Bytes(), e)
+	e.StatusCode = resp.StatusCode
+	e.Endpoint = endpoint
+	e.RequestID = resp.Header.Get("X-Request-Id")
+	e.sentinel = sentinelForStatus(resp.StatusCode)
+	return e
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maxExecutionsWindow is the longest range the executions endpoint accepts in one request.
+const maxExecutionsWindow = 30 * 24 * time.Hour
+
+type Execution struct {
+	Id                       int       `json:"id"`
+	Symbol                   string    `json:"symbol"`
+	SymbolId                 int       `json:"symbolId"`
+	Quantity                 float64   `json:"quantity"`
+	Side                     OrderSide `json:"side"`
+	Price                    float64   `json:"price"`
+	OrderId                  int       `json:"orderId"`
+	OrderChainId             int       `json:"orderChainId"`
+	ExchangeExecId           string    `json:"exchangeExecId"`
+	Timestamp                Time      `json:"timestamp"`
+	Notes                    string    `json:"notes"`
+	Venue                    string    `json:"venue"`
+	TotalCost                float64   `json:"totalCost"`
+	OrderPlacementCommission float64   `json:"orderPlacementCommission"`
+	Commission               float64   `json:"commission"`
+	ExecutionFee             float64   `json:"executionFee"`
+	SecFee                   float64   `json:"secFee"`
+	CanadianExecutionFee     float64   `json:"canadianExecutionFee"`
+	ParentId                 int       `json:"parentId"`
+}
+
+type ExecutionsResponse struct {
+	Executions []Execution `json:"executions"`
+}
+
+// Executions lists the executions of account id between start and end. Long
+// ranges are fetched in windows the server accepts; executions are returned
+// in the order the server reports them, without duplicates.
+func (c *Client) Executions(ctx context.Context, id string, start, end time.Time) ([]Execution, error) {
+	var executions []Execution
+	var dedup windowDedup
+	endpoint := fmt.Sprintf("v1/accounts/%v/executions", id)
+	for _, w := range splitRange(start, end, maxExecutionsWindow) {
+		result := &ExecutionsResponse{}
+		params := map[string]string{
+			"startTime": formatTime(w.Start),
+			"endTime":   formatTime(w.End),
+		}
+		if err := c.getJSON(ctx, endpoint, params, result); err != nil {
+			return nil, err
+		}
+		dedup.next()
+		for _, e := range result.Executions {
+			if dedup.keep(strconv.Itoa(e.Id)) {
+				executions = append(executions, e)
+			}
+		}
+	}
+	return executions, nil
+}
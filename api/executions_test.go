@@ -0,0 +1,54 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+// historyStart is where the account history ranges in the tests begin.
+var historyStart = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func day(n int) time.Time {
+	return historyStart.AddDate(0, 0, n)
+}
+
+func TestExecutionsWindows(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	execution := func(id int, at time.Time) api.Execution {
+		return api.Execution{Id: id, Symbol: "VFV.TO", SymbolId: 9292, Quantity: 1, Timestamp: api.Time{Time: at}}
+	}
+	server.UpdateFixtures(func(f *questradetest.Fixtures) {
+		f.Executions["11111111"] = []api.Execution{
+			execution(1, day(0)),
+			execution(2, day(10)),
+			// On the boundaries of the first and second 30-day windows.
+			execution(3, day(30)),
+			execution(4, day(60)),
+			execution(5, day(61)),
+			// Outside the range.
+			execution(6, day(62)),
+		}
+	})
+	c := newTestClient(t, server)
+
+	// 62 days take three windows of at most 30 days; the server rejects longer ones.
+	executions, err := c.Executions(context.Background(), "11111111", day(0), day(62).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, e := range executions {
+		ids = append(ids, e.Id)
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 || ids[3] != 4 || ids[4] != 5 {
+		t.Errorf("got executions %v, want 1 to 5 once each", ids)
+	}
+	if n := server.Count("v1/accounts/11111111/executions"); n != 3 {
+		t.Errorf("sent %v requests, want 3", n)
+	}
+}
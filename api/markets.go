@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+	// Embed the zone database so marketZones load on systems without one.
+	_ "time/tzdata"
+)
+
+type Market struct {
+	Name                 string   `json:"name"`
+	TradingVenues        []string `json:"tradingVenues"`
+	DefaultTradingVenue  string   `json:"defaultTradingVenue"`
+	PrimaryOrderRoutes   []string `json:"primaryOrderRoutes"`
+	SecondaryOrderRoutes []string `json:"secondaryOrderRoutes"`
+	Level1Feeds          []string `json:"level1Feeds"`
+	Level2Feeds          []string `json:"level2Feeds"`
+	ExtendedStartTime    Time     `json:"extendedStartTime"`
+	StartTime            Time     `json:"startTime"`
+	EndTime              Time     `json:"endTime"`
+	ExtendedEndTime      Time     `json:"extendedEndTime"`
+	Currency             string   `json:"currency"`
+	SnapQuotesLimit      int      `json:"snapQuotesLimit"`
+}
+
+type MarketsResponse struct {
+	Markets []Market `json:"markets"`
+}
+
+type timeResponse struct {
+	Time Time `json:"time"`
+}
+
+// Markets lists the markets Questrade trades on, with today's session times.
+func (c *Client) Markets(ctx context.Context) (*MarketsResponse, error) {
+	result := &MarketsResponse{}
+	if err := c.getJSON(ctx, "v1/markets", nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ServerTime returns the current time of the API server.
+func (c *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	result := &timeResponse{}
+	if err := c.getJSON(ctx, "v1/time", nil, result); err != nil {
+		return time.Time{}, err
+	}
+	return result.Time.Time, nil
+}
+
+// marketZones maps market names to the time zone their sessions follow, so
+// session times stay right across daylight saving changes.
+var marketZones = map[string]string{
+	"TSX":        "America/Toronto",
+	"TSXV":       "America/Toronto",
+	"CNSX":       "America/Toronto",
+	"MX":         "America/Toronto",
+	"NYSE":       "America/New_York",
+	"NASDAQ":     "America/New_York",
+	"NYSEAM":     "America/New_York",
+	"ARCA":       "America/New_York",
+	"OPRA":       "America/New_York",
+	"PinkSheets": "America/New_York",
+	"OTCBB":      "America/New_York",
+}
+
+// clock is a time of day in a market's zone.
+type clock struct {
+	hour, min, sec int
+}
+
+func clockOf(t time.Time) clock {
+	return clock{t.Hour(), t.Minute(), t.Second()}
+}
+
+func (c clock) on(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, c.hour, c.min, c.sec, 0, day.Location())
+}
+
+type marketHours struct {
+	location      *time.Location
+	extendedStart clock
+	start         clock
+	end           clock
+	extendedEnd   clock
+}
+
+// MarketCalendar answers whether markets are open, based on the session
+// times reported by v1/markets, weekends and the holidays added to it.
+type MarketCalendar struct {
+	// Skew is how far the server clock is ahead of the local clock.
+	Skew     time.Duration
+	hours    map[string]marketHours
+	holidays map[string]map[string]struct{}
+}
+
+// NewMarketCalendar fetches the markets and the server time and builds a calendar from them.
+func NewMarketCalendar(ctx context.Context, c *Client) (*MarketCalendar, error) {
+	markets, err := c.Markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	before := time.Now()
+	server, err := c.ServerTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	after := time.Now()
+	mc := &MarketCalendar{
+		Skew:     server.Sub(before.Add(after.Sub(before) / 2)),
+		hours:    map[string]marketHours{},
+		holidays: map[string]map[string]struct{}{},
+	}
+	for _, m := range markets.Markets {
+		loc := m.StartTime.Location()
+		if name, ok := marketZones[m.Name]; ok {
+			if loc, err = time.LoadLocation(name); err != nil {
+				return nil, fmt.Errorf("unable to load the time zone of %v: %w", m.Name, err)
+			}
+		}
+		mc.hours[m.Name] = marketHours{
+			location:      loc,
+			extendedStart: clockOf(m.ExtendedStartTime.In(loc)),
+			start:         clockOf(m.StartTime.In(loc)),
+			end:           clockOf(m.EndTime.In(loc)),
+			extendedEnd:   clockOf(m.ExtendedEndTime.In(loc)),
+		}
+	}
+	return mc, nil
+}
+
+// AddHoliday marks date as a day market is closed.
+func (mc *MarketCalendar) AddHoliday(market string, date time.Time) {
+	if mc.holidays[market] == nil {
+		mc.holidays[market] = map[string]struct{}{}
+	}
+	mc.holidays[market][date.Format("2006-01-02")] = struct{}{}
+}
+
+// Now returns the current time according to the server clock.
+func (mc *MarketCalendar) Now() time.Time {
+	return time.Now().Add(mc.Skew)
+}
+
+func (mc *MarketCalendar) marketHours(market string) (marketHours, error) {
+	h, ok := mc.hours[market]
+	if !ok {
+		return h, fmt.Errorf("unknown market %q", market)
+	}
+	return h, nil
+}
+
+func (mc *MarketCalendar) tradingDay(market string, day time.Time) bool {
+	switch day.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	_, holiday := mc.holidays[market][day.Format("2006-01-02")]
+	return !holiday
+}
+
+// IsTradingDay reports whether market has a session on the day of t.
+func (mc *MarketCalendar) IsTradingDay(market string, t time.Time) (bool, error) {
+	h, err := mc.marketHours(market)
+	if err != nil {
+		return false, err
+	}
+	return mc.tradingDay(market, t.In(h.location)), nil
+}
+
+// IsOpen reports whether the regular session of market is open at t.
+func (mc *MarketCalendar) IsOpen(market string, t time.Time) (bool, error) {
+	h, err := mc.marketHours(market)
+	if err != nil {
+		return false, err
+	}
+	t = t.In(h.location)
+	if !mc.tradingDay(market, t) {
+		return false, nil
+	}
+	return !t.Before(h.start.on(t)) && t.Before(h.end.on(t)), nil
+}
+
+// IsExtendedOpen reports whether market is open at t, including pre-market and after-hours sessions.
+func (mc *MarketCalendar) IsExtendedOpen(market string, t time.Time) (bool, error) {
+	h, err := mc.marketHours(market)
+	if err != nil {
+		return false, err
+	}
+	t = t.In(h.location)
+	if !mc.tradingDay(market, t) {
+		return false, nil
+	}
+	return !t.Before(h.extendedStart.on(t)) && t.Before(h.extendedEnd.on(t)), nil
+}
+
+// NextOpen returns when the regular session of market next opens after t.
+// If the market is open at t, the start of the following session is returned.
+func (mc *MarketCalendar) NextOpen(market string, t time.Time) (time.Time, error) {
+	h, err := mc.marketHours(market)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.In(h.location)
+	// A year of holidays and weekends in a row would be a broken calendar.
+	for day := t; day.Before(t.AddDate(1, 0, 0)); day = day.AddDate(0, 0, 1) {
+		open := h.start.on(day)
+		if open.After(t) && mc.tradingDay(market, day) {
+			return open, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v does not open within a year of %v", market, t)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// gapOverlap widens the range refetched after a reconnect to cover clock
+// differences; duplicates are filtered out.
+const gapOverlap = time.Minute
+
+// openOrdersLookback is how far back orders still open after a reconnect are
+// looked for, to catch good-till-cancelled orders created on earlier days.
+const openOrdersLookback = 365 * 24 * time.Hour
+
+type NotificationKind string
+
+const (
+	OrderNotification     NotificationKind = "Order"
+	ExecutionNotification NotificationKind = "Execution"
+)
+
+// Notification is an order state change or an execution in one of the
+// user's accounts. Exactly one of Order and Execution is set, according to
+// Kind. Replayed is true for events recovered through the REST endpoints
+// after the stream reconnected.
+type Notification struct {
+	Kind          NotificationKind
+	AccountNumber string
+	Order         *Order
+	Execution     *Execution
+	Replayed      bool
+}
+
+type notificationMessage struct {
+	AccountNumber string      `json:"accountNumber"`
+	Orders        []Order     `json:"orders"`
+	Executions    []Execution `json:"executions"`
+}
+
+// NotificationStream delivers order and execution events as they happen.
+type NotificationStream struct {
+	client        *Client
+	streamer      *streamer
+	notifications chan Notification
+	accounts      []string
+	// since is when events were last known to be complete, per account.
+	since map[string]time.Time
+	// orders and executions hold the update time of the orders and the time
+	// of the executions delivered, to skip them when they are recovered again.
+	orders     map[int]time.Time
+	executions map[int]time.Time
+}
+
+// StreamNotifications subscribes to order and execution notifications of the
+// given accounts, or of every account of the user if none are given. When
+// the stream reconnects, events missed while it was down are fetched from
+// the orders and executions endpoints and delivered with Replayed set.
+func (c *Client) StreamNotifications(ctx context.Context, accounts ...string) (*NotificationStream, error) {
+	if len(accounts) == 0 {
+		result, err := c.Accounts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range result.Accounts {
+			accounts = append(accounts, a.Number)
+		}
+	}
+	ns := &NotificationStream{
+		client:        c,
+		notifications: make(chan Notification, 64),
+		accounts:      accounts,
+		since:         map[string]time.Time{},
+		orders:        map[int]time.Time{},
+		executions:    map[int]time.Time{},
+	}
+	now := time.Now()
+	for _, a := range accounts {
+		ns.since[a] = now
+	}
+	ns.streamer = newStreamer(ctx, c, "v1/notifications", map[string]string{"mode": "WebSocket"}, ns.handle)
+	ns.streamer.reconnected = ns.fillGap
+	if err := ns.streamer.start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ns.streamer.stopped
+		close(ns.notifications)
+	}()
+	return ns, nil
+}
+
+func (ns *NotificationStream) watching(account string) bool {
+	_, ok := ns.since[account]
+	return ok
+}
+
+func (ns *NotificationStream) handle(message []byte) {
+	m := &notificationMessage{}
+	if err := json.Unmarshal(message, m); err != nil {
+		ns.streamer.report(fmt.Errorf("unable to decode notification: %w", err))
+		return
+	}
+	if m.AccountNumber != "" && !ns.watching(m.AccountNumber) {
+		return
+	}
+	ns.deliver(m.AccountNumber, m.Orders, m.Executions, false)
+	if m.AccountNumber != "" {
+		ns.since[m.AccountNumber] = time.Now()
+	}
+}
+
+// deliver sends the events not delivered before.
+func (ns *NotificationStream) deliver(account string, orders []Order, executions []Execution, replayed bool) {
+	for i := range orders {
+		o := &orders[i]
+		if last, ok := ns.orders[o.Id]; ok && !o.UpdateTime.After(last) {
+			continue
+		}
+		ns.orders[o.Id] = o.UpdateTime.Time
+		ns.send(Notification{Kind: OrderNotification, AccountNumber: account, Order: o, Replayed: replayed})
+	}
+	for i := range executions {
+		e := &executions[i]
+		if _, ok := ns.executions[e.Id]; ok {
+			continue
+		}
+		ns.executions[e.Id] = e.Timestamp.Time
+		ns.send(Notification{Kind: ExecutionNotification, AccountNumber: account, Execution: e, Replayed: replayed})
+	}
+}
+
+func (ns *NotificationStream) send(n Notification) {
+	select {
+	case ns.notifications <- n:
+	case <-ns.streamer.done:
+	}
+}
+
+// fillGap fetches the orders and executions of every account since it last
+// heard from the stream.
+func (ns *NotificationStream) fillGap() {
+	now := time.Now()
+	for _, account := range ns.accounts {
+		start := ns.since[account].Add(-gapOverlap)
+		executions, err := ns.client.Executions(ns.streamer.ctx, account, start, now)
+		if err != nil {
+			ns.streamer.report(fmt.Errorf("unable to recover executions of %v: %w", account, err))
+			continue
+		}
+		orders, err := ns.changedOrders(account, start, now, executions)
+		if err != nil {
+			ns.streamer.report(fmt.Errorf("unable to recover orders of %v: %w", account, err))
+			continue
+		}
+		ns.deliver(account, orders, executions, true)
+		ns.since[account] = now
+	}
+	ns.prune()
+}
+
+// changedOrders returns the orders of account updated since start. The orders
+// endpoint filters on creation time, so it looks at the orders created today,
+// at the orders still open however old, such as good-till-cancelled ones,
+// and at the orders the executions belong to, which may have closed.
+func (ns *NotificationStream) changedOrders(account string, start, now time.Time, executions []Execution) ([]Order, error) {
+	ctx := ns.streamer.ctx
+	y, m, d := start.Date()
+	today, err := ns.client.Orders(ctx, account, time.Date(y, m, d, 0, 0, 0, 0, start.Location()), now, OrderStateFilterAll)
+	if err != nil {
+		return nil, err
+	}
+	open, err := ns.client.Orders(ctx, account, now.Add(-openOrdersLookback), now, OrderStateFilterOpen)
+	if err != nil {
+		return nil, err
+	}
+	orders := append(today.Orders, open.Orders...)
+	found := map[int]bool{}
+	for _, o := range orders {
+		found[o.Id] = true
+	}
+	var filled []int
+	for _, e := range executions {
+		if !found[e.OrderId] {
+			found[e.OrderId] = true
+			filled = append(filled, e.OrderId)
+		}
+	}
+	if len(filled) > 0 {
+		result, err := ns.client.OrdersByID(ctx, account, filled...)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, result.Orders...)
+	}
+	// Orders changed in the gap have an update time inside it.
+	var changed []Order
+	seen := map[int]bool{}
+	for _, o := range orders {
+		if !seen[o.Id] && !o.UpdateTime.Before(start) {
+			changed = append(changed, o)
+		}
+		seen[o.Id] = true
+	}
+	return changed, nil
+}
+
+// prune forgets the orders and executions older than any gap fillGap can
+// recover, since it skips those by their time anyway. It runs on every
+// reconnect, which happens at least whenever the access token is refreshed.
+func (ns *NotificationStream) prune() {
+	cutoff := time.Now()
+	for _, since := range ns.since {
+		if since.Before(cutoff) {
+			cutoff = since
+		}
+	}
+	cutoff = cutoff.Add(-gapOverlap)
+	for id, updated := range ns.orders {
+		if updated.Before(cutoff) {
+			delete(ns.orders, id)
+		}
+	}
+	for id, at := range ns.executions {
+		if at.Before(cutoff) {
+			delete(ns.executions, id)
+		}
+	}
+}
+
+// Notifications returns the channel events are delivered on. It is closed after Close.
+func (ns *NotificationStream) Notifications() <-chan Notification {
+	return ns.notifications
+}
+
+// Each calls fn for every event until the stream is closed.
+func (ns *NotificationStream) Each(fn func(Notification)) {
+	for n := range ns.notifications {
+		fn(n)
+	}
+}
+
+// Errors returns the channel recoverable stream errors are reported on.
+func (ns *NotificationStream) Errors() <-chan error {
+	return ns.streamer.errs
+}
+
+// Close stops the stream.
+func (ns *NotificationStream) Close() error {
+	ns.streamer.close()
+	return nil
+}
@@ -0,0 +1,120 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+func nextNotification(t *testing.T, ns *api.NotificationStream) api.Notification {
+	t.Helper()
+	select {
+	case n, ok := <-ns.Notifications():
+		if !ok {
+			t.Fatal("notification stream closed")
+		}
+		return n
+	case <-time.After(10 * time.Second):
+		t.Fatal("no notification received")
+	}
+	return api.Notification{}
+}
+
+// describe summarizes n for comparisons.
+func describe(n api.Notification) string {
+	if n.Kind == api.OrderNotification {
+		return fmt.Sprintf("%v order %v %v replayed %v", n.AccountNumber, n.Order.Id, n.Order.State, n.Replayed)
+	}
+	return fmt.Sprintf("%v execution %v replayed %v", n.AccountNumber, n.Execution.Id, n.Replayed)
+}
+
+// waitForReconnect waits until the notification stream connected again after
+// it had asked for a stream port ports times.
+func waitForReconnect(t *testing.T, server *questradetest.Server, ports int) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); server.Count("v1/notifications") <= ports || server.Streams() != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not reconnect")
+		}
+	}
+}
+
+func TestStreamNotificationsGap(t *testing.T) {
+	ctx := context.Background()
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	// Two good-till-cancelled orders from two days ago.
+	created := api.Time{Time: time.Now().AddDate(0, 0, -2)}
+	gtc := func(id int, state api.OrderState, filled float64) api.Order {
+		return api.Order{Id: id, ChainId: id, Symbol: "ZCN.TO", SymbolId: 40261, Side: api.OrderSideBuy,
+			OrderType: api.OrderTypeLimit, LimitPrice: 27, TimeInForce: api.TimeInForceGoodTillCanceled,
+			TotalQuantity: 100, FilledQuantity: filled, OpenQuantity: 100 - filled, State: state,
+			CreationTime: created, UpdateTime: api.Time{Time: time.Now()}}
+	}
+	fill := func(id, orderId int, quantity float64) api.Execution {
+		return api.Execution{Id: id, OrderId: orderId, OrderChainId: orderId, Symbol: "ZCN.TO", SymbolId: 40261,
+			Side: api.OrderSideBuy, Quantity: quantity, Price: 27, Timestamp: api.Time{Time: time.Now()}}
+	}
+	server.UpdateFixtures(func(f *questradetest.Fixtures) {
+		f.Orders["11111111"] = []api.Order{gtc(500, api.OrderStateAccepted, 0), gtc(501, api.OrderStateAccepted, 0)}
+		for i := range f.Orders["11111111"] {
+			f.Orders["11111111"][i].UpdateTime = created
+		}
+	})
+	c := newTestClient(t, server)
+	c.Options.SkipOrderImpact = true
+
+	ns, err := c.StreamNotifications(ctx, "11111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+
+	// Changes are delivered live, and only for the accounts asked for.
+	server.PushOrders("22222222", api.Order{Id: 600, ChainId: 600, State: api.OrderStateAccepted})
+	placed := placeLimit(t, c, 95).OrderId
+	if got, want := describe(nextNotification(t, ns)), fmt.Sprintf("11111111 order %v Accepted replayed false", placed); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Keep the stream down for a second while the account changes.
+	server.FailStreamAuth()
+	server.FailStreamAuth()
+	server.DropStreams()
+	other := newTestClient(t, server)
+	if _, err = other.CancelOrder(ctx, "11111111", placed); err != nil {
+		t.Fatal(err)
+	}
+	server.PushOrders("11111111", gtc(500, api.OrderStatePartial, 40), gtc(501, api.OrderStateExecuted, 100))
+	server.PushExecutions("11111111", fill(900, 500, 40), fill(901, 501, 100))
+
+	// The cancelled day order, the open order from two days ago and the
+	// order closed by its execution are recovered after reconnecting.
+	want := map[string]bool{
+		fmt.Sprintf("11111111 order %v Canceled replayed true", placed): true,
+		"11111111 order 500 Partial replayed true":                      true,
+		"11111111 order 501 Executed replayed true":                     true,
+		"11111111 execution 900 replayed true":                          true,
+		"11111111 execution 901 replayed true":                          true,
+	}
+	for len(want) > 0 {
+		got := describe(nextNotification(t, ns))
+		if !want[got] {
+			t.Fatalf("got %v, still waiting for %v", got, want)
+		}
+		delete(want, got)
+	}
+
+	// Another reconnect recovers the same events, which are not delivered twice.
+	ports := server.Count("v1/notifications")
+	server.DropStreams()
+	waitForReconnect(t, server, ports)
+	server.PushExecutions("11111111", fill(902, 500, 10))
+	if got, want := describe(nextNotification(t, ns)), "11111111 execution 902 replayed false"; got != want {
+		t.Errorf("got %v after reconnecting again, want only the new execution %v", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultAuthorizeURL is the Questrade OAuth page a user logs in and grants access on.
+const DefaultAuthorizeURL = "https://login.questrade.com/oauth2/authorize"
+
+// OAuthConfig describes a consumer registered in the Questrade app hub.
+type OAuthConfig struct {
+	// ClientID is the consumer key.
+	ClientID string
+	// RedirectURI is the callback URL registered for the consumer. It must
+	// be a plain http URL on this machine, e.g. http://localhost:8765/callback,
+	// as Authorize listens on it.
+	RedirectURI string
+	// AuthorizeURL defaults to DefaultAuthorizeURL.
+	AuthorizeURL string
+	// Implicit uses the implicit flow, which hands out the tokens directly,
+	// instead of the authorization code flow.
+	Implicit bool
+}
+
+// authorizeURL returns the URL the user opens to grant access.
+func (cfg *OAuthConfig) authorizeURL(state string) string {
+	base := cfg.AuthorizeURL
+	if base == "" {
+		base = DefaultAuthorizeURL
+	}
+	responseType := "code"
+	if cfg.Implicit {
+		responseType = "token"
+	}
+	q := url.Values{
+		"client_id":     {cfg.ClientID},
+		"response_type": {responseType},
+		"redirect_uri":  {cfg.RedirectURI},
+		"state":         {state},
+	}
+	return base + "?" + q.Encode()
+}
+
+// forwardFragment sends the tokens of the implicit flow, which the browser
+// keeps in the URL fragment, back to the listener as a query string.
+const forwardFragment = `<html><body><script>
+if (location.hash.length > 1) {
+	location.replace(location.pathname + "?" + location.hash.substring(1));
+} else {
+	document.body.textContent = "No tokens received.";
+}
+</script></body></html>`
+
+// Authorize runs the OAuth flow of cfg and makes the granted session the
+// client's session, saving it to the TokenStore if there is one. show is
+// called with the URL the user has to open; Authorize then waits on
+// cfg.RedirectURI until the login server redirects the browser back with
+// the state of this login, turning away requests with any other state, and
+// for the authorization code flow redeems the code at the client's LoginURL.
+func (c *Client) Authorize(ctx context.Context, cfg OAuthConfig, show func(authorizeURL string)) (*Session, error) {
+	redirect, err := url.Parse(cfg.RedirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect uri: %w", err)
+	}
+	if redirect.Scheme != "http" {
+		return nil, fmt.Errorf("redirect uri %v must use http to be served locally", cfg.RedirectURI)
+	}
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	state := hex.EncodeToString(nonce)
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v: %w", redirect.Host, err)
+	}
+	results := make(chan url.Values, 1)
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if len(q) == 0 {
+			if !cfg.Implicit {
+				http.Error(w, "Missing authorization response", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, forwardFragment)
+			return
+		}
+		// Anything without this login's state, e.g. a stale tab or a
+		// forged request, is turned away and the login keeps waiting.
+		if q.Get("state") != state {
+			http.Error(w, "Authorization state does not match this login", http.StatusBadRequest)
+			return
+		}
+		select {
+		case results <- q:
+			fmt.Fprintln(w, "Done, you can close this window.")
+		default:
+			http.Error(w, "Already answered", http.StatusConflict)
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer func() {
+		// Let the page answering the callback reach the browser.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	show(cfg.authorizeURL(state))
+
+	var q url.Values
+	select {
+	case q = <-results:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if e := q.Get("error"); e != "" {
+		return nil, fmt.Errorf("authorization denied: %v %v", e, q.Get("error_description"))
+	}
+	if cfg.Implicit {
+		return c.setSessionFromValues(q)
+	}
+	code := q.Get("code")
+	if code == "" {
+		return nil, errors.New("redirect carried no authorization code")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requestToken(ctx, "exchange authorization code", map[string]string{
+		"client_id":    cfg.ClientID,
+		"code":         code,
+		"grant_type":   "authorization_code",
+		"redirect_uri": cfg.RedirectURI,
+	})
+}
+
+// setSessionFromValues makes the tokens handed out by the implicit flow the client's session.
+func (c *Client) setSessionFromValues(q url.Values) (*Session, error) {
+	session := &Session{
+		AccessToken:  q.Get("access_token"),
+		ApiServer:    q.Get("api_server"),
+		RefreshToken: q.Get("refresh_token"),
+		TokenType:    q.Get("token_type"),
+	}
+	if session.AccessToken == "" {
+		return nil, errors.New("redirect carried no access token")
+	}
+	session.ExpiresIn, _ = strconv.Atoi(q.Get("expires_in"))
+	session.ExpiresAt = time.Now().Add(time.Duration(session.ExpiresIn) * time.Second)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setSession(session)
+}
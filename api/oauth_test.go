@@ -0,0 +1,184 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+// redirectURI returns a callback URL on a port that was free a moment ago.
+func redirectURI(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return "http://" + l.Addr().String() + "/callback"
+}
+
+// noRedirect is a browser that shows redirects instead of following them.
+var noRedirect = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// get fetches rawurl like a browser would, from the goroutine standing in
+// for the user. Failures are reported with t.Errorf, which unlike t.Fatal
+// may be called from there; the returned response is never nil.
+func get(t *testing.T, client *http.Client, rawurl string) (*http.Response, string) {
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		t.Errorf("GET %v: %v", rawurl, err)
+		return &http.Response{Header: http.Header{}}, ""
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+// browse runs user in the background as the user's browser and returns a
+// channel closed once it is done.
+func browse(user func()) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		user()
+	}()
+	return done
+}
+
+func oauthClient(server *questradetest.Server) (*api.Client, api.OAuthConfig) {
+	c := api.NewClient(nil)
+	c.LoginURL = server.LoginURL()
+	return c, api.OAuthConfig{ClientID: "consumer", AuthorizeURL: server.AuthorizeURL()}
+}
+
+func checkSession(t *testing.T, server *questradetest.Server, c *api.Client, session *api.Session) {
+	t.Helper()
+	if session.RefreshToken != server.RefreshToken() || session.ApiServer != server.URL+"/" {
+		t.Errorf("got session %+v, want the one the server issued last", session)
+	}
+	if _, err := c.Accounts(context.Background()); err != nil {
+		t.Errorf("granted session does not work: %v", err)
+	}
+}
+
+func TestAuthorizeCode(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c, cfg := oauthClient(server)
+	cfg.RedirectURI = redirectURI(t)
+
+	var done chan struct{}
+	session, err := c.Authorize(context.Background(), cfg, func(authorizeURL string) {
+		done = browse(func() {
+			// The browser follows the redirect back to the listener.
+			if resp, body := get(t, http.DefaultClient, authorizeURL); resp.StatusCode != 200 {
+				t.Errorf("callback answered %v: %v", resp.Status, body)
+			}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	checkSession(t, server, c, session)
+	if n := server.Count("POST oauth2/token"); n != 1 {
+		t.Errorf("exchanged the code %v times, want 1", n)
+	}
+}
+
+func TestAuthorizeImplicit(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c, cfg := oauthClient(server)
+	cfg.RedirectURI = redirectURI(t)
+	cfg.Implicit = true
+
+	var done chan struct{}
+	session, err := c.Authorize(context.Background(), cfg, func(authorizeURL string) {
+		done = browse(func() {
+			resp, _ := get(t, noRedirect, authorizeURL)
+			location, err := url.Parse(resp.Header.Get("Location"))
+			if err != nil || location.Fragment == "" {
+				t.Errorf("authorize redirected to %q, want tokens in the fragment", resp.Header.Get("Location"))
+				return
+			}
+			// Browsers do not send the fragment, so the listener answers
+			// with a page that sends it back as a query string.
+			fragment := location.Fragment
+			location.Fragment = ""
+			if _, body := get(t, http.DefaultClient, location.String()); !strings.Contains(body, "location.hash") {
+				t.Errorf("callback without query answered %q, want the forwarding page", body)
+			}
+			location.RawQuery = fragment
+			if resp, body := get(t, http.DefaultClient, location.String()); resp.StatusCode != 200 {
+				t.Errorf("forwarded fragment answered %v: %v", resp.Status, body)
+			}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	checkSession(t, server, c, session)
+	if n := server.Count("POST oauth2/token"); n != 0 {
+		t.Errorf("redeemed %v times, want none for the implicit flow", n)
+	}
+}
+
+func TestAuthorizeBadState(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c, cfg := oauthClient(server)
+	cfg.RedirectURI = redirectURI(t)
+
+	var done chan struct{}
+	session, err := c.Authorize(context.Background(), cfg, func(authorizeURL string) {
+		done = browse(func() {
+			// A forged callback is turned away without ending the login.
+			forged := cfg.RedirectURI + "?" + url.Values{"code": {"forged"}, "state": {"forged"}}.Encode()
+			if resp, _ := get(t, http.DefaultClient, forged); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("forged callback answered %v, want 400", resp.Status)
+			}
+			if resp, body := get(t, http.DefaultClient, authorizeURL); resp.StatusCode != 200 {
+				t.Errorf("callback answered %v: %v", resp.Status, body)
+			}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	checkSession(t, server, c, session)
+}
+
+func TestAuthorizeCancelled(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c, cfg := oauthClient(server)
+	cfg.RedirectURI = redirectURI(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// Nobody opens the URL.
+	if _, err := c.Authorize(ctx, cfg, func(string) {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	// The listener is closed again, so the port can be used for the next try.
+	l, err := net.Listen("tcp", strings.TrimSuffix(strings.TrimPrefix(cfg.RedirectURI, "http://"), "/callback"))
+	if err != nil {
+		t.Errorf("redirect listener still open: %v", err)
+	} else {
+		l.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type StrikePrice struct {
+	StrikePrice  float64 `json:"strikePrice"`
+	CallSymbolId int     `json:"callSymbolId"`
+	PutSymbolId  int     `json:"putSymbolId"`
+}
+
+// OptionRootChain lists the strikes of one option root, e.g. an adjusted root after a corporate action.
+type OptionRootChain struct {
+	OptionRoot          string        `json:"optionRoot"`
+	Multiplier          int           `json:"multiplier"`
+	ChainPerStrikePrice []StrikePrice `json:"chainPerStrikePrice"`
+}
+
+// OptionExpiry lists the strikes of every option root expiring on ExpiryDate.
+type OptionExpiry struct {
+	ExpiryDate         Time              `json:"expiryDate"`
+	Description        string            `json:"description"`
+	ListingExchange    string            `json:"listingExchange"`
+	OptionExerciseType string            `json:"optionExerciseType"`
+	ChainPerRoot       []OptionRootChain `json:"chainPerRoot"`
+}
+
+type OptionChainResponse struct {
+	OptionChain []OptionExpiry `json:"optionChain"`
+}
+
+// Expiry returns the expiry of the chain falling on the same day as date.
+func (r *OptionChainResponse) Expiry(date time.Time) (OptionExpiry, bool) {
+	y, m, d := date.Date()
+	for _, e := range r.OptionChain {
+		ey, em, ed := e.ExpiryDate.Date()
+		if ey == y && em == m && ed == d {
+			return e, true
+		}
+	}
+	return OptionExpiry{}, false
+}
+
+// OptionFilter selects option quotes of one underlying and expiry. Zero
+// strike bounds and an empty OptionType are not applied.
+type OptionFilter struct {
+	OptionType     OptionType `json:"optionType,omitempty"`
+	UnderlyingId   int        `json:"underlyingId"`
+	ExpiryDate     time.Time  `json:"expiryDate"`
+	MinStrikePrice float64    `json:"minstrikePrice,omitempty"`
+	MaxStrikePrice float64    `json:"maxstrikePrice,omitempty"`
+}
+
+type optionQuotesRequest struct {
+	Filters   []OptionFilter `json:"filters,omitempty"`
+	OptionIds []int          `json:"optionIds,omitempty"`
+}
+
+// OptionQuote is a level 1 option quote with its greeks. Volatility is the implied volatility.
+type OptionQuote struct {
+	Underlying          string   `json:"underlying"`
+	UnderlyingId        int      `json:"underlyingId"`
+	Symbol              string   `json:"symbol"`
+	SymbolId            int      `json:"symbolId"`
+	BidPrice            float64  `json:"bidPrice"`
+	BidSize             float64  `json:"bidSize"`
+	AskPrice            float64  `json:"askPrice"`
+	AskSize             float64  `json:"askSize"`
+	LastTradePriceTrHrs float64  `json:"lastTradePriceTrHrs"`
+	LastTradePrice      float64  `json:"lastTradePrice"`
+	LastTradeSize       float64  `json:"lastTradeSize"`
+	LastTradeTick       TickType `json:"lastTradeTick"`
+	LastTradeTime       Time     `json:"lastTradeTime"`
+	Volume              float64  `json:"volume"`
+	OpenPrice           float64  `json:"openPrice"`
+	HighPrice           float64  `json:"highPrice"`
+	LowPrice            float64  `json:"lowPrice"`
+	Volatility          float64  `json:"volatility"`
+	Delta               float64  `json:"delta"`
+	Gamma               float64  `json:"gamma"`
+	Theta               float64  `json:"theta"`
+	Vega                float64  `json:"vega"`
+	Rho                 float64  `json:"rho"`
+	OpenInterest        float64  `json:"openInterest"`
+	Delay               int      `json:"delay"`
+	IsHalted            bool     `json:"isHalted"`
+	VWAP                float64  `json:"VWAP"`
+}
+
+// Mid returns the midpoint of the bid and ask, or the last trade price if either side is missing.
+func (q *OptionQuote) Mid() float64 {
+	if q.BidPrice == 0 || q.AskPrice == 0 {
+		return q.LastTradePrice
+	}
+	return (q.BidPrice + q.AskPrice) / 2
+}
+
+type OptionQuotesResponse struct {
+	OptionQuotes []OptionQuote `json:"optionQuotes"`
+}
+
+// OptionChain returns the option chain of the underlying symbol id.
+func (c *Client) OptionChain(ctx context.Context, id int) (*OptionChainResponse, error) {
+	result := &OptionChainResponse{}
+	if err := c.getJSON(ctx, fmt.Sprintf("v1/symbols/%d/options", id), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OptionQuotes returns quotes for the options matching filters and for the
+// option symbol ids. Long id lists are split across several requests.
+func (c *Client) OptionQuotes(ctx context.Context, filters []OptionFilter, ids ...int) ([]OptionQuote, error) {
+	requests := []optionQuotesRequest{}
+	if len(filters) > 0 {
+		requests = append(requests, optionQuotesRequest{Filters: filters})
+	}
+	for _, chunk := range chunkIds(ids, maxQuoteIds) {
+		requests = append(requests, optionQuotesRequest{OptionIds: chunk})
+	}
+	var quotes []OptionQuote
+	for _, req := range requests {
+		result := &OptionQuotesResponse{}
+		if err := c.queryJSON(ctx, "v1/markets/quotes/options", req, result); err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, result.OptionQuotes...)
+	}
+	return quotes, nil
+}
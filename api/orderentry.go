@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRoute lets Questrade pick the venue an order is routed to.
+const DefaultRoute = "AUTO"
+
+// OrderRequest describes an order to place, replace or preview. For trailing
+// stop orders StopPrice holds the trail offset, in dollars or percent
+// depending on OrderType.
+type OrderRequest struct {
+	AccountNumber   string      `json:"accountNumber"`
+	SymbolId        int         `json:"symbolId"`
+	Quantity        float64     `json:"quantity"`
+	IcebergQuantity float64     `json:"icebergQuantity,omitempty"`
+	LimitPrice      float64     `json:"limitPrice,omitempty"`
+	StopPrice       float64     `json:"stopPrice,omitempty"`
+	IsAllOrNone     bool        `json:"isAllOrNone"`
+	IsAnonymous     bool        `json:"isAnonymous"`
+	OrderType       OrderType   `json:"orderType"`
+	TimeInForce     TimeInForce `json:"timeInForce"`
+	Action          OrderSide   `json:"action"`
+	PrimaryRoute    string      `json:"primaryRoute"`
+	SecondaryRoute  string      `json:"secondaryRoute"`
+	GtdDate         *time.Time  `json:"gtdDate,omitempty"`
+}
+
+// NewOrderRequest returns a day order routed automatically. Set LimitPrice,
+// StopPrice or TimeInForce on the result as OrderType requires.
+func NewOrderRequest(accountNumber string, symbolId int, action OrderSide, quantity float64, orderType OrderType) *OrderRequest {
+	return &OrderRequest{
+		AccountNumber:  accountNumber,
+		SymbolId:       symbolId,
+		Quantity:       quantity,
+		OrderType:      orderType,
+		TimeInForce:    TimeInForceDay,
+		Action:         action,
+		PrimaryRoute:   DefaultRoute,
+		SecondaryRoute: DefaultRoute,
+	}
+}
+
+// ErrInvalidOrder is wrapped by the errors returned when an order request is rejected before it is sent.
+var ErrInvalidOrder = errors.New("invalid order")
+
+func invalidOrder(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %v", ErrInvalidOrder, fmt.Sprintf(format, args...))
+}
+
+// Validate checks that the prices the order type needs are set.
+func (r *OrderRequest) Validate() error {
+	if r.AccountNumber == "" {
+		return invalidOrder("account number is required")
+	}
+	if r.SymbolId == 0 {
+		return invalidOrder("symbol id is required")
+	}
+	if r.Quantity <= 0 {
+		return invalidOrder("quantity must be positive, got %v", r.Quantity)
+	}
+	switch r.OrderType {
+	case OrderTypeMarket:
+	case OrderTypeLimit, OrderTypeLimitOnOpen, OrderTypeLimitOnClose:
+		if r.LimitPrice <= 0 {
+			return invalidOrder("%v order needs a limit price", r.OrderType)
+		}
+	case OrderTypeStop, OrderTypeTrailStopInDollar, OrderTypeTrailStopInPercentage:
+		if r.StopPrice <= 0 {
+			return invalidOrder("%v order needs a stop price", r.OrderType)
+		}
+	case OrderTypeStopLimit, OrderTypeTrailStopLimitInDollar, OrderTypeTrailStopLimitInPercentage:
+		if r.StopPrice <= 0 || r.LimitPrice <= 0 {
+			return invalidOrder("%v order needs a stop and a limit price", r.OrderType)
+		}
+	default:
+		return invalidOrder("unknown order type %q", r.OrderType)
+	}
+	switch r.TimeInForce {
+	case TimeInForceGoodTillDate:
+		if r.GtdDate == nil {
+			return invalidOrder("GoodTillDate order needs a gtd date")
+		}
+	case "":
+		return invalidOrder("time in force is required")
+	}
+	if r.Action == "" {
+		return invalidOrder("action is required")
+	}
+	return nil
+}
+
+// OrderImpact is the pre-trade estimate of an order's effect on the account.
+type OrderImpact struct {
+	EstimatedCommissions  float64   `json:"estimatedCommissions"`
+	BuyingPowerResult     float64   `json:"buyingPowerResult"`
+	BuyingPowerEffect     float64   `json:"buyingPowerEffect"`
+	EquityEffect          float64   `json:"equityEffect"`
+	MaintExcess           float64   `json:"maintExcess"`
+	Side                  OrderSide `json:"side"`
+	TradeValueCalculation string    `json:"tradeValueCalculation"`
+	Price                 float64   `json:"price"`
+}
+
+// OrderResult is returned when an order is placed or replaced. Impact is the
+// preview the order was checked against, or nil if previews are skipped.
+type OrderResult struct {
+	OrderId int          `json:"orderId"`
+	Orders  []Order      `json:"orders"`
+	Impact  *OrderImpact `json:"-"`
+}
+
+// OrderImpact previews req without placing it.
+func (c *Client) OrderImpact(ctx context.Context, req *OrderRequest) (*OrderImpact, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	result := &OrderImpact{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/impact", req.AccountNumber)
+	if err := c.queryJSON(ctx, endpoint, req, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// preview fetches the impact of order unless previews are skipped, and lets
+// ConfirmOrder veto it.
+func (c *Client) preview(ctx context.Context, order interface{}, impact func() (*OrderImpact, error)) (*OrderImpact, error) {
+	if c.Options.SkipOrderImpact {
+		return nil, nil
+	}
+	result, err := impact()
+	if err != nil {
+		return nil, err
+	}
+	if c.Options.ConfirmOrder != nil {
+		if err = c.Options.ConfirmOrder(order, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// PlaceOrder submits req. Unless ClientOptions.SkipOrderImpact is set the
+// order is previewed first and only placed if the preview succeeds and
+// ClientOptions.ConfirmOrder accepts it.
+func (c *Client) PlaceOrder(ctx context.Context, req *OrderRequest) (*OrderResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	impact, err := c.preview(ctx, req, func() (*OrderImpact, error) { return c.OrderImpact(ctx, req) })
+	if err != nil {
+		return nil, err
+	}
+	result := &OrderResult{Impact: impact}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders", req.AccountNumber)
+	if err = c.postJSON(ctx, endpoint, req, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
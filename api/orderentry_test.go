@@ -0,0 +1,155 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+func TestOrderRequestValidate(t *testing.T) {
+	gtd := time.Now().AddDate(0, 0, 7)
+	order := func(orderType api.OrderType, limit, stop float64) *api.OrderRequest {
+		r := api.NewOrderRequest("11111111", 9292, api.OrderSideBuy, 10, orderType)
+		r.LimitPrice = limit
+		r.StopPrice = stop
+		return r
+	}
+	market := func(modify func(*api.OrderRequest)) *api.OrderRequest {
+		r := order(api.OrderTypeMarket, 0, 0)
+		modify(r)
+		return r
+	}
+	tests := []struct {
+		name  string
+		req   *api.OrderRequest
+		valid bool
+	}{
+		{"market", order(api.OrderTypeMarket, 0, 0), true},
+		{"limit", order(api.OrderTypeLimit, 100, 0), true},
+		{"limit without price", order(api.OrderTypeLimit, 0, 0), false},
+		{"limit on open", order(api.OrderTypeLimitOnOpen, 100, 0), true},
+		{"limit on open without price", order(api.OrderTypeLimitOnOpen, 0, 0), false},
+		{"limit on close", order(api.OrderTypeLimitOnClose, 100, 0), true},
+		{"limit on close without price", order(api.OrderTypeLimitOnClose, 0, 0), false},
+		{"stop", order(api.OrderTypeStop, 0, 95), true},
+		{"stop without price", order(api.OrderTypeStop, 100, 0), false},
+		{"trail stop in dollar", order(api.OrderTypeTrailStopInDollar, 0, 2), true},
+		{"trail stop in dollar without price", order(api.OrderTypeTrailStopInDollar, 0, 0), false},
+		{"trail stop in percentage", order(api.OrderTypeTrailStopInPercentage, 0, 5), true},
+		{"trail stop in percentage without price", order(api.OrderTypeTrailStopInPercentage, 0, 0), false},
+		{"stop limit", order(api.OrderTypeStopLimit, 94, 95), true},
+		{"stop limit without limit", order(api.OrderTypeStopLimit, 0, 95), false},
+		{"stop limit without stop", order(api.OrderTypeStopLimit, 94, 0), false},
+		{"trail stop limit in dollar", order(api.OrderTypeTrailStopLimitInDollar, 1, 2), true},
+		{"trail stop limit in dollar without limit", order(api.OrderTypeTrailStopLimitInDollar, 0, 2), false},
+		{"trail stop limit in percentage", order(api.OrderTypeTrailStopLimitInPercentage, 1, 5), true},
+		{"trail stop limit in percentage without stop", order(api.OrderTypeTrailStopLimitInPercentage, 1, 0), false},
+		{"unknown type", order("Iceberg", 100, 0), false},
+		{"no account", market(func(r *api.OrderRequest) { r.AccountNumber = "" }), false},
+		{"no symbol", market(func(r *api.OrderRequest) { r.SymbolId = 0 }), false},
+		{"zero quantity", market(func(r *api.OrderRequest) { r.Quantity = 0 }), false},
+		{"negative quantity", market(func(r *api.OrderRequest) { r.Quantity = -1 }), false},
+		{"no action", market(func(r *api.OrderRequest) { r.Action = "" }), false},
+		{"no time in force", market(func(r *api.OrderRequest) { r.TimeInForce = "" }), false},
+		{"good till date", market(func(r *api.OrderRequest) { r.TimeInForce = api.TimeInForceGoodTillDate; r.GtdDate = &gtd }), true},
+		{"good till date without date", market(func(r *api.OrderRequest) { r.TimeInForce = api.TimeInForceGoodTillDate }), false},
+	}
+	for _, tt := range tests {
+		err := tt.req.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, api.ErrInvalidOrder) {
+			t.Errorf("%v: got %v, want api.ErrInvalidOrder", tt.name, err)
+		}
+	}
+}
+
+func TestPlaceOrderPreview(t *testing.T) {
+	ctx := context.Background()
+	impact := "POST v1/accounts/11111111/orders/impact"
+	place := "POST v1/accounts/11111111/orders"
+	errDeclined := errors.New("declined")
+	tests := []struct {
+		name    string
+		options api.ClientOptions
+		// failure is injected into the preview.
+		failure *questradetest.Failure
+		err     error
+		// previewed and placed are whether the preview and the order reached the server.
+		previewed, placed bool
+	}{
+		{name: "previewed by default", previewed: true, placed: true},
+		{name: "confirmed", options: api.ClientOptions{ConfirmOrder: func(interface{}, *api.OrderImpact) error { return nil }}, previewed: true, placed: true},
+		{name: "declined", options: api.ClientOptions{ConfirmOrder: func(interface{}, *api.OrderImpact) error { return errDeclined }}, err: errDeclined, previewed: true},
+		{name: "preview failed", failure: &questradetest.Failure{Status: 400, Code: questradetest.CodeInvalidArgument, Message: "Insufficient buying power"}, previewed: true},
+		{name: "preview skipped", options: api.ClientOptions{SkipOrderImpact: true}, placed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+			c := newTestClient(t, server)
+			c.Options = tt.options
+			if tt.failure != nil {
+				server.Fail(impact, *tt.failure)
+			}
+
+			req := limitOrder(95)
+			result, err := c.PlaceOrder(ctx, req)
+			switch {
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Errorf("got %v, want %v", err, tt.err)
+			case tt.failure != nil && err == nil:
+				t.Error("order placed although its preview failed")
+			case tt.placed && err != nil:
+				t.Fatal(err)
+			}
+			if n := server.Count(impact); (n == 1) != tt.previewed || n > 1 {
+				t.Errorf("previewed %v times, want previewed %v", n, tt.previewed)
+			}
+			if n := server.Count(place); (n == 1) != tt.placed || n > 1 {
+				t.Errorf("sent the order %v times, want placed %v", n, tt.placed)
+			}
+			if !tt.placed {
+				return
+			}
+			if requests := server.Requests(); tt.previewed && requests[len(requests)-2] != impact {
+				t.Errorf("requests %v, want the preview right before the order", requests)
+			}
+			if tt.previewed && (result.Impact == nil || result.Impact.Price != 95) {
+				t.Errorf("got impact %+v, want the preview at 95", result.Impact)
+			}
+			if !tt.previewed && result.Impact != nil {
+				t.Errorf("got impact %+v without a preview", result.Impact)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderConfirm(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c := newTestClient(t, server)
+	req := limitOrder(95)
+	var confirmed interface{}
+	var shown *api.OrderImpact
+	c.Options.ConfirmOrder = func(order interface{}, impact *api.OrderImpact) error {
+		confirmed, shown = order, impact
+		return nil
+	}
+	result, err := c.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed != req || shown != result.Impact {
+		t.Errorf("confirmed %v with %+v, want the request with the impact returned", confirmed, shown)
+	}
+	if shown.BuyingPowerEffect != -950 || shown.Side != api.OrderSideBuy {
+		t.Errorf("got impact %+v, want 10 x 95 bought", shown)
+	}
+}
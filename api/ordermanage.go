@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrMutationInProgress is returned when the same cancel or replace is
+// requested again while the first request has not finished.
+var ErrMutationInProgress = errors.New("the same order change is already in progress")
+
+// mutationRetention is how long the result of a cancel or replace is kept
+// to answer repeats of it. It only needs to outlast the caller's retries.
+const mutationRetention = 10 * time.Minute
+
+// mutationLog remembers cancel and replace requests by key so a retried
+// request is answered from the first outcome instead of being sent twice.
+type mutationLog struct {
+	mu sync.Mutex
+	// done holds the result of every request that succeeded in the last
+	// mutationRetention.
+	done map[string]mutationResult
+	// uncertain holds requests that failed in a way that does not tell
+	// whether the server acted on them, e.g. a dropped connection.
+	uncertain map[string]bool
+	inflight  map[string]bool
+}
+
+type mutationResult struct {
+	result interface{}
+	at     time.Time
+}
+
+// begin claims key. It returns the earlier result if key already succeeded,
+// and whether the earlier outcome is unknown.
+func (l *mutationLog) begin(key string) (result interface{}, uncertain bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done == nil {
+		l.done = map[string]mutationResult{}
+		l.uncertain = map[string]bool{}
+		l.inflight = map[string]bool{}
+	}
+	now := time.Now()
+	for k, r := range l.done {
+		if now.Sub(r.at) > mutationRetention {
+			delete(l.done, k)
+		}
+	}
+	if r, ok := l.done[key]; ok {
+		return r.result, false, nil
+	}
+	if l.inflight[key] {
+		return nil, false, ErrMutationInProgress
+	}
+	l.inflight[key] = true
+	return nil, l.uncertain[key], nil
+}
+
+// finish releases key and records the outcome of the request.
+func (l *mutationLog) finish(key string, result interface{}, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.inflight, key)
+	if err == nil {
+		l.done[key] = mutationResult{result, time.Now()}
+		delete(l.uncertain, key)
+		return
+	}
+	if outcomeUnknown(err) {
+		l.uncertain[key] = true
+	}
+}
+
+// outcomeUnknown reports whether err leaves open if the server applied the request.
+func outcomeUnknown(err error) bool {
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return !errors.Is(err, ErrInvalidOrder)
+}
+
+type CancelResult struct {
+	OrderId int `json:"orderId"`
+}
+
+// CancelOrder cancels order orderId of account id. Cancelling the same order
+// again within ten minutes returns the first result without contacting the
+// server. If an earlier attempt ended without a clear answer, the order is
+// looked up first and the cancel only resent if the order is still live.
+func (c *Client) CancelOrder(ctx context.Context, id string, orderId int) (*CancelResult, error) {
+	key := fmt.Sprintf("cancel/%v/%d", id, orderId)
+	done, uncertain, err := c.mutations.begin(key)
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		return done.(*CancelResult), nil
+	}
+	result, err := c.cancelOrder(ctx, id, orderId, uncertain)
+	c.mutations.finish(key, result, err)
+	return result, err
+}
+
+func (c *Client) cancelOrder(ctx context.Context, id string, orderId int, uncertain bool) (*CancelResult, error) {
+	if uncertain {
+		order, err := c.Order(ctx, id, orderId)
+		if err != nil {
+			return nil, err
+		}
+		if order.State == OrderStateCanceled || order.State == OrderStateCancelPending {
+			return &CancelResult{OrderId: orderId}, nil
+		}
+	}
+	result := &CancelResult{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/%d", id, orderId)
+	if err := c.sendJSON(ctx, "DELETE", endpoint, nil, result, false); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReplaceOrder replaces order orderId with req, e.g. to reprice a limit
+// order. Like PlaceOrder the replacement is previewed first unless
+// ClientOptions.SkipOrderImpact is set. Repeating the same replacement
+// within ten minutes returns the first result, and after an attempt without
+// a clear answer the order is looked up before anything is resent.
+func (c *Client) ReplaceOrder(ctx context.Context, orderId int, req *OrderRequest) (*OrderResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("replace/%v/%d/%x", req.AccountNumber, orderId, sha256.Sum256(body))
+	done, uncertain, err := c.mutations.begin(key)
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		return done.(*OrderResult), nil
+	}
+	result, err := c.replaceOrder(ctx, orderId, req, uncertain)
+	c.mutations.finish(key, result, err)
+	return result, err
+}
+
+func (c *Client) replaceOrder(ctx context.Context, orderId int, req *OrderRequest, uncertain bool) (*OrderResult, error) {
+	if uncertain {
+		result, err := c.findReplacement(ctx, req, orderId)
+		if err != nil || result != nil {
+			return result, err
+		}
+	}
+	impact, err := c.preview(ctx, req, func() (*OrderImpact, error) { return c.OrderImpact(ctx, req) })
+	if err != nil {
+		return nil, err
+	}
+	result := &OrderResult{Impact: impact}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/%d", req.AccountNumber, orderId)
+	if err = c.sendJSON(ctx, "POST", endpoint, req, result, false); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findReplacement returns the order that replaced orderId with req, or nil
+// if orderId has not been replaced. It fails if orderId was replaced by
+// something other than req, or if its replacement can not be found yet.
+func (c *Client) findReplacement(ctx context.Context, req *OrderRequest, orderId int) (*OrderResult, error) {
+	original, err := c.Order(ctx, req.AccountNumber, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if original.State != OrderStateReplaced && original.State != OrderStateReplacePending {
+		return nil, nil
+	}
+	// A replacement shares the chain id of the order it replaces. The end is
+	// sent rounded down to the second, so leave room for one created just now.
+	orders, err := c.Orders(ctx, req.AccountNumber, original.CreationTime.Time, time.Now().Add(time.Minute), OrderStateFilterAll)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders.Orders {
+		if o.ChainId != original.ChainId || o.Id <= orderId {
+			continue
+		}
+		if !replacedWith(&o, req) {
+			return nil, fmt.Errorf("order %d was replaced by order %d, which does not match this replacement", orderId, o.Id)
+		}
+		return &OrderResult{OrderId: o.Id, Orders: []Order{o}}, nil
+	}
+	return nil, fmt.Errorf("order %d is %v but its replacement was not found", orderId, original.State)
+}
+
+// replacedWith reports whether o carries the terms of req.
+func replacedWith(o *Order, req *OrderRequest) bool {
+	return o.SymbolId == req.SymbolId &&
+		o.TotalQuantity == req.Quantity &&
+		o.OrderType == req.OrderType &&
+		o.LimitPrice == req.LimitPrice &&
+		o.StopPrice == req.StopPrice &&
+		o.TimeInForce == req.TimeInForce
+}
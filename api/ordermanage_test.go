@@ -0,0 +1,253 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+// placeLimit places a limit order to buy 10 VFV.TO at price in account 11111111.
+func placeLimit(t *testing.T, c *api.Client, price float64) *api.OrderResult {
+	t.Helper()
+	result, err := c.PlaceOrder(context.Background(), limitOrder(price))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func limitOrder(price float64) *api.OrderRequest {
+	req := api.NewOrderRequest("11111111", 9292, api.OrderSideBuy, 10, api.OrderTypeLimit)
+	req.LimitPrice = price
+	return req
+}
+
+func orderPath(orderId int) string {
+	return fmt.Sprintf("v1/accounts/11111111/orders/%d", orderId)
+}
+
+func orderState(t *testing.T, c *api.Client, orderId int) api.OrderState {
+	t.Helper()
+	o, err := c.Order(context.Background(), "11111111", orderId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o.State
+}
+
+func TestCancelOrder(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// failure is injected into the first cancel.
+		failure *questradetest.Failure
+		// deletes is how many cancels reach the server over both attempts.
+		deletes int
+	}{
+		{"repeated", nil, 1},
+		{"server error", &questradetest.Failure{Status: 503, Message: "Service unavailable"}, 2},
+		{"server error after cancelling", &questradetest.Failure{Status: 500, Message: "Internal error", After: true}, 1},
+		{"dropped connection", &questradetest.Failure{Drop: true}, 2},
+		{"dropped connection after cancelling", &questradetest.Failure{Drop: true, After: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+			c := newTestClient(t, server)
+			c.Options.SkipOrderImpact = true
+			orderId := placeLimit(t, c, 95).OrderId
+
+			if tt.failure != nil {
+				server.Fail("DELETE "+orderPath(orderId), *tt.failure)
+				if _, err := c.CancelOrder(ctx, "11111111", orderId); err == nil {
+					t.Fatal("first cancel succeeded despite the failure")
+				}
+			} else if _, err := c.CancelOrder(ctx, "11111111", orderId); err != nil {
+				t.Fatal(err)
+			}
+			result, err := c.CancelOrder(ctx, "11111111", orderId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.OrderId != orderId {
+				t.Errorf("cancelled order %v, want %v", result.OrderId, orderId)
+			}
+			if n := server.Count("DELETE " + orderPath(orderId)); n != tt.deletes {
+				t.Errorf("sent %v cancels, want %v", n, tt.deletes)
+			}
+			// Only an unclear outcome makes the retry look the order up.
+			lookups := 0
+			if tt.failure != nil {
+				lookups = 1
+			}
+			if n := server.Count("GET " + orderPath(orderId)); n != lookups {
+				t.Errorf("looked the order up %v times, want %v", n, lookups)
+			}
+			if s := orderState(t, c, orderId); s != api.OrderStateCanceled {
+				t.Errorf("order is %v, want Canceled", s)
+			}
+		})
+	}
+}
+
+func TestCancelOrderRejected(t *testing.T) {
+	ctx := context.Background()
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c := newTestClient(t, server)
+	c.Options.SkipOrderImpact = true
+	orderId := placeLimit(t, c, 95).OrderId
+
+	// A clear rejection is not remembered as uncertain, so the next
+	// attempt is sent without looking the order up.
+	server.Fail("DELETE "+orderPath(orderId), questradetest.Failure{Status: 400, Code: questradetest.CodeInvalidArgument, Message: "Rejected"})
+	if _, err := c.CancelOrder(ctx, "11111111", orderId); err == nil {
+		t.Fatal("rejected cancel succeeded")
+	}
+	if _, err := c.CancelOrder(ctx, "11111111", orderId); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Count("GET " + orderPath(orderId)); n != 0 {
+		t.Errorf("looked the order up %v times after a clear rejection, want none", n)
+	}
+}
+
+func TestReplaceOrder(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		failure *questradetest.Failure
+		// replaces is how many replacements reach the server over both attempts.
+		replaces int
+	}{
+		{"repeated", nil, 1},
+		{"server error", &questradetest.Failure{Status: 502, Message: "Bad gateway"}, 2},
+		{"server error after replacing", &questradetest.Failure{Status: 500, Message: "Internal error", After: true}, 1},
+		{"dropped connection", &questradetest.Failure{Drop: true}, 2},
+		{"dropped connection after replacing", &questradetest.Failure{Drop: true, After: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+			c := newTestClient(t, server)
+			original := placeLimit(t, c, 95).Orders[0]
+
+			if tt.failure != nil {
+				server.Fail("POST "+orderPath(original.Id), *tt.failure)
+				if _, err := c.ReplaceOrder(ctx, original.Id, limitOrder(96)); err == nil {
+					t.Fatal("first replace succeeded despite the failure")
+				}
+			} else if _, err := c.ReplaceOrder(ctx, original.Id, limitOrder(96)); err != nil {
+				t.Fatal(err)
+			}
+			result, err := c.ReplaceOrder(ctx, original.Id, limitOrder(96))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Orders) != 1 || result.Orders[0].ChainId != original.ChainId || result.Orders[0].LimitPrice != 96 {
+				t.Errorf("got %+v, want the replacement at 96 in chain %v", result.Orders, original.ChainId)
+			}
+			if n := server.Count("POST " + orderPath(original.Id)); n != tt.replaces {
+				t.Errorf("sent %v replacements, want %v", n, tt.replaces)
+			}
+			if s := orderState(t, c, original.Id); s != api.OrderStateReplaced {
+				t.Errorf("original order is %v, want Replaced", s)
+			}
+			orders, err := c.Orders(ctx, "11111111", original.CreationTime.Time, original.CreationTime.AddDate(0, 0, 1), api.OrderStateFilterOpen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(orders.Orders) != 1 || orders.Orders[0].Id != result.OrderId {
+				t.Errorf("open orders %+v, want only the replacement %v", orders.Orders, result.OrderId)
+			}
+		})
+	}
+}
+
+func TestReplaceOrderInProgress(t *testing.T) {
+	ctx := context.Background()
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c := newTestClient(t, server)
+	orderId := placeLimit(t, c, 95).OrderId
+
+	// The confirmation runs while the first replacement is in flight.
+	var repeated error
+	c.Options.ConfirmOrder = func(interface{}, *api.OrderImpact) error {
+		_, repeated = c.ReplaceOrder(ctx, orderId, limitOrder(96))
+		return nil
+	}
+	if _, err := c.ReplaceOrder(ctx, orderId, limitOrder(96)); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(repeated, api.ErrMutationInProgress) {
+		t.Errorf("repeated replacement while in flight: got %v, want ErrMutationInProgress", repeated)
+	}
+	if n := server.Count("POST " + orderPath(orderId)); n != 1 {
+		t.Errorf("sent %v replacements, want 1", n)
+	}
+}
+
+func TestReplaceOrderUncertain(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// meanwhile changes the original order after the first attempt
+		// failed without reaching the server.
+		meanwhile func(t *testing.T, server *questradetest.Server, orderId int)
+		err       string
+		// replaces is how many replacements reach the server.
+		replaces int
+	}{
+		{
+			name: "replaced by another session",
+			meanwhile: func(t *testing.T, server *questradetest.Server, orderId int) {
+				other := newTestClient(t, server)
+				other.Options.SkipOrderImpact = true
+				if _, err := other.ReplaceOrder(ctx, orderId, limitOrder(97)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err:      "does not match this replacement",
+			replaces: 2,
+		},
+		{
+			name: "replacement not listed yet",
+			meanwhile: func(t *testing.T, server *questradetest.Server, orderId int) {
+				server.UpdateFixtures(func(f *questradetest.Fixtures) {
+					f.Orders["11111111"][0].State = api.OrderStateReplacePending
+				})
+			},
+			err:      "replacement was not found",
+			replaces: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+			c := newTestClient(t, server)
+			orderId := placeLimit(t, c, 95).OrderId
+
+			server.Fail("POST "+orderPath(orderId), questradetest.Failure{Drop: true})
+			if _, err := c.ReplaceOrder(ctx, orderId, limitOrder(96)); err == nil {
+				t.Fatal("replace succeeded over a dropped connection")
+			}
+			tt.meanwhile(t, server, orderId)
+			_, err := c.ReplaceOrder(ctx, orderId, limitOrder(96))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error saying %q", err, tt.err)
+			}
+			if n := server.Count("POST " + orderPath(orderId)); n != tt.replaces {
+				t.Errorf("sent %v replacements, want %v", n, tt.replaces)
+			}
+		})
+	}
+}
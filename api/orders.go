@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type OrderSide string
+
+const (
+	OrderSideBuy   OrderSide = "Buy"
+	OrderSideSell  OrderSide = "Sell"
+	OrderSideShort OrderSide = "Short"
+	OrderSideCover OrderSide = "Cov"
+	// Option sides: buy/sell to open/close.
+	OrderSideBTO OrderSide = "BTO"
+	OrderSideSTC OrderSide = "STC"
+	OrderSideSTO OrderSide = "STO"
+	OrderSideBTC OrderSide = "BTC"
+)
+
+type OrderType string
+
+const (
+	OrderTypeMarket                     OrderType = "Market"
+	OrderTypeLimit                      OrderType = "Limit"
+	OrderTypeStop                       OrderType = "Stop"
+	OrderTypeStopLimit                  OrderType = "StopLimit"
+	OrderTypeTrailStopInPercentage      OrderType = "TrailStopInPercentage"
+	OrderTypeTrailStopInDollar          OrderType = "TrailStopInDollar"
+	OrderTypeTrailStopLimitInPercentage OrderType = "TrailStopLimitInPercentage"
+	OrderTypeTrailStopLimitInDollar     OrderType = "TrailStopLimitInDollar"
+	OrderTypeLimitOnOpen                OrderType = "LimitOnOpen"
+	OrderTypeLimitOnClose               OrderType = "LimitOnClose"
+)
+
+type TimeInForce string
+
+const (
+	TimeInForceDay                 TimeInForce = "Day"
+	TimeInForceGoodTillCanceled    TimeInForce = "GoodTillCanceled"
+	TimeInForceGoodTillExtendedDay TimeInForce = "GoodTillExtendedDay"
+	TimeInForceGoodTillDate        TimeInForce = "GoodTillDate"
+	TimeInForceImmediateOrCancel   TimeInForce = "ImmediateOrCancel"
+	TimeInForceFillOrKill          TimeInForce = "FillOrKill"
+)
+
+type OrderState string
+
+const (
+	OrderStateFailed            OrderState = "Failed"
+	OrderStatePending           OrderState = "Pending"
+	OrderStateAccepted          OrderState = "Accepted"
+	OrderStateRejected          OrderState = "Rejected"
+	OrderStateCancelPending     OrderState = "CancelPending"
+	OrderStateCanceled          OrderState = "Canceled"
+	OrderStatePartialCanceled   OrderState = "PartialCanceled"
+	OrderStatePartial           OrderState = "Partial"
+	OrderStateExecuted          OrderState = "Executed"
+	OrderStateReplacePending    OrderState = "ReplacePending"
+	OrderStateReplaced          OrderState = "Replaced"
+	OrderStateStopped           OrderState = "Stopped"
+	OrderStateSuspended         OrderState = "Suspended"
+	OrderStateExpired           OrderState = "Expired"
+	OrderStateQueued            OrderState = "Queued"
+	OrderStateTriggered         OrderState = "Triggered"
+	OrderStateActivated         OrderState = "Activated"
+	OrderStatePendingRiskReview OrderState = "PendingRiskReview"
+	OrderStateContingentOrder   OrderState = "ContingentOrder"
+)
+
+// Closed reports whether the order can no longer be filled.
+func (s OrderState) Closed() bool {
+	switch s {
+	case OrderStateFailed, OrderStateRejected, OrderStateCanceled, OrderStatePartialCanceled,
+		OrderStateExecuted, OrderStateReplaced, OrderStateExpired:
+		return true
+	}
+	return false
+}
+
+// OrderStateFilter selects which orders are listed by Client.Orders.
+type OrderStateFilter string
+
+const (
+	OrderStateFilterAll    OrderStateFilter = "All"
+	OrderStateFilterOpen   OrderStateFilter = "Open"
+	OrderStateFilterClosed OrderStateFilter = "Closed"
+)
+
+type OrderClass string
+
+const (
+	OrderClassPrimary  OrderClass = "Primary"
+	OrderClassLimit    OrderClass = "Limit"
+	OrderClassStopLoss OrderClass = "StopLoss"
+)
+
+// OrderLeg is one leg of a multi-leg option strategy order.
+type OrderLeg struct {
+	LegId            int       `json:"legId"`
+	Symbol           string    `json:"symbol"`
+	SymbolId         int       `json:"symbolId"`
+	LegRatioQuantity float64   `json:"legRatioQuantity"`
+	Side             OrderSide `json:"side"`
+	AvgExecPrice     float64   `json:"avgExecPrice"`
+	LastExecPrice    float64   `json:"lastExecPrice"`
+}
+
+type Order struct {
+	Id                       int         `json:"id"`
+	Symbol                   string      `json:"symbol"`
+	SymbolId                 int         `json:"symbolId"`
+	TotalQuantity            float64     `json:"totalQuantity"`
+	OpenQuantity             float64     `json:"openQuantity"`
+	FilledQuantity           float64     `json:"filledQuantity"`
+	CanceledQuantity         float64     `json:"canceledQuantity"`
+	Side                     OrderSide   `json:"side"`
+	OrderType                OrderType   `json:"orderType"`
+	LimitPrice               float64     `json:"limitPrice"`
+	StopPrice                float64     `json:"stopPrice"`
+	IsAllOrNone              bool        `json:"isAllOrNone"`
+	IsAnonymous              bool        `json:"isAnonymous"`
+	IcebergQuantity          float64     `json:"icebergQuantity"`
+	MinQuantity              float64     `json:"minQuantity"`
+	AvgExecPrice             float64     `json:"avgExecPrice"`
+	LastExecPrice            float64     `json:"lastExecPrice"`
+	Source                   string      `json:"source"`
+	TimeInForce              TimeInForce `json:"timeInForce"`
+	GtdDate                  Time        `json:"gtdDate"`
+	State                    OrderState  `json:"state"`
+	RejectionReason          string      `json:"rejectionReason"`
+	ChainId                  int         `json:"chainId"`
+	CreationTime             Time        `json:"creationTime"`
+	UpdateTime               Time        `json:"updateTime"`
+	Notes                    string      `json:"notes"`
+	PrimaryRoute             string      `json:"primaryRoute"`
+	SecondaryRoute           string      `json:"secondaryRoute"`
+	OrderRoute               string      `json:"orderRoute"`
+	VenueHoldingOrder        string      `json:"venueHoldingOrder"`
+	CommissionCharged        float64     `json:"comissionCharged"`
+	ExchangeOrderId          string      `json:"exchangeOrderId"`
+	IsSignificantShareHolder bool        `json:"isSignificantShareHolder"`
+	IsInsider                bool        `json:"isInsider"`
+	IsLimitOffsetInDollar    bool        `json:"isLimitOffsetInDollar"`
+	UserId                   int         `json:"userId"`
+	PlacementCommission      float64     `json:"placementCommission"`
+	Legs                     []OrderLeg  `json:"legs"`
+	StrategyType             string      `json:"strategyType"`
+	TriggerStopPrice         float64     `json:"triggerStopPrice"`
+	OrderGroupId             int         `json:"orderGroupId"`
+	OrderClass               OrderClass  `json:"orderClass"`
+}
+
+type OrdersResponse struct {
+	Orders []Order `json:"orders"`
+}
+
+// Orders lists the orders of account id created between start and end that
+// match stateFilter. A zero start or end leaves the bound to the server,
+// which defaults to the current day.
+func (c *Client) Orders(ctx context.Context, id string, start, end time.Time, stateFilter OrderStateFilter) (*OrdersResponse, error) {
+	params := map[string]string{}
+	if !start.IsZero() {
+		params["startTime"] = formatTime(start)
+	}
+	if !end.IsZero() {
+		params["endTime"] = formatTime(end)
+	}
+	if stateFilter != "" {
+		params["stateFilter"] = string(stateFilter)
+	}
+	result := &OrdersResponse{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders", id)
+	if err := c.getJSON(ctx, endpoint, params, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OrdersByID fetches the orders of account id with the given order ids.
+func (c *Client) OrdersByID(ctx context.Context, id string, orderIds ...int) (*OrdersResponse, error) {
+	result := &OrdersResponse{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders", id)
+	if err := c.getJSON(ctx, endpoint, map[string]string{"ids": joinIds(orderIds)}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Order fetches a single order of account id.
+func (c *Client) Order(ctx context.Context, id string, orderId int) (*Order, error) {
+	result := &OrdersResponse{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/%v", id, orderId)
+	if err := c.getJSON(ctx, endpoint, nil, result); err != nil {
+		return nil, err
+	}
+	if len(result.Orders) == 0 {
+		return nil, &ApiError{StatusCode: 404, Endpoint: endpoint, Message: "order not found", sentinel: ErrNotFound}
+	}
+	return &result.Orders[0], nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import "context"
+
+// maxQuoteIds is how many symbol ids are requested per quotes call.
+const maxQuoteIds = 100
+
+type TickType string
+
+const (
+	TickTypeUp    TickType = "Up"
+	TickTypeDown  TickType = "Down"
+	TickTypeEqual TickType = "Equal"
+)
+
+// Quote is a level 1 quote. Delay is the number of minutes the data is delayed by.
+type Quote struct {
+	Symbol              string   `json:"symbol"`
+	SymbolId            int      `json:"symbolId"`
+	Tier                string   `json:"tier"`
+	BidPrice            float64  `json:"bidPrice"`
+	BidSize             float64  `json:"bidSize"`
+	AskPrice            float64  `json:"askPrice"`
+	AskSize             float64  `json:"askSize"`
+	LastTradePriceTrHrs float64  `json:"lastTradePriceTrHrs"`
+	LastTradePrice      float64  `json:"lastTradePrice"`
+	LastTradeSize       float64  `json:"lastTradeSize"`
+	LastTradeTick       TickType `json:"lastTradeTick"`
+	LastTradeTime       Time     `json:"lastTradeTime"`
+	Volume              float64  `json:"volume"`
+	OpenPrice           float64  `json:"openPrice"`
+	HighPrice           float64  `json:"highPrice"`
+	LowPrice            float64  `json:"lowPrice"`
+	High52w             float64  `json:"high52w"`
+	Low52w              float64  `json:"low52w"`
+	VWAP                float64  `json:"VWAP"`
+	Delay               int      `json:"delay"`
+	IsHalted            bool     `json:"isHalted"`
+}
+
+type QuotesResponse struct {
+	Quotes []Quote `json:"quotes"`
+}
+
+// chunkIds splits ids into batches of at most size ids.
+func chunkIds(ids []int, size int) [][]int {
+	var chunks [][]int
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// Quotes returns level 1 quotes for the given symbol ids. Large batches are
+// split across several requests.
+func (c *Client) Quotes(ctx context.Context, ids ...int) ([]Quote, error) {
+	var quotes []Quote
+	for _, chunk := range chunkIds(ids, maxQuoteIds) {
+		result := &QuotesResponse{}
+		if err := c.getJSON(ctx, "v1/markets/quotes", map[string]string{"ids": joinIds(chunk)}, result); err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, result.Quotes...)
+	}
+	return quotes, nil
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateCategory is the rate-limit bucket a request is counted against.
+// Questrade budgets account calls and market data calls separately.
+type RateCategory int
+
+const (
+	AccountCalls RateCategory = iota
+	MarketDataCalls
+)
+
+func (c RateCategory) String() string {
+	if c == MarketDataCalls {
+		return "MarketDataCalls"
+	}
+	return "AccountCalls"
+}
+
+// maxRateLimitRetries is how many times a request answered with 429 is
+// retried after waiting for the budget to reset.
+const maxRateLimitRetries = 3
+
+// categoryFor returns the rate-limit bucket of endpoint.
+func categoryFor(endpoint string) RateCategory {
+	if strings.HasPrefix(endpoint, "v1/markets") || strings.HasPrefix(endpoint, "v1/symbols") {
+		return MarketDataCalls
+	}
+	return AccountCalls
+}
+
+// RateBudget is the last known request budget of a RateCategory as reported
+// by the X-RateLimit-Remaining and X-RateLimit-Reset headers.
+type RateBudget struct {
+	Remaining int
+	Reset     time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	budgets map[RateCategory]RateBudget
+}
+
+// reserve takes one request from the budget of category. If the budget is
+// spent it returns how long to wait for the reset instead.
+func (l *rateLimiter) reserve(category RateCategory) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	budget, ok := l.budgets[category]
+	if !ok {
+		return 0
+	}
+	now := time.Now()
+	if !now.Before(budget.Reset) {
+		// The window has rolled over; the next response tells us the new budget.
+		delete(l.budgets, category)
+		return 0
+	}
+	if budget.Remaining <= 0 {
+		return budget.Reset.Sub(now)
+	}
+	budget.Remaining--
+	l.budgets[category] = budget
+	return 0
+}
+
+// update records the budget reported in header.
+func (l *rateLimiter) update(category RateCategory, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	l.set(category, RateBudget{remaining, time.Unix(reset, 0)})
+}
+
+// exhaust marks the budget of category as spent after a 429 response.
+func (l *rateLimiter) exhaust(category RateCategory, header http.Header) {
+	reset := time.Now().Add(time.Second)
+	if r, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(r, 0)
+	} else if s, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		reset = time.Now().Add(time.Duration(s) * time.Second)
+	}
+	l.set(category, RateBudget{0, reset})
+}
+
+func (l *rateLimiter) set(category RateCategory, budget RateBudget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.budgets == nil {
+		l.budgets = map[RateCategory]RateBudget{}
+	}
+	l.budgets[category] = budget
+}
+
+// RateBudget returns the last known budget of category. ok is false until a
+// response for that category has reported one, or once its reset has passed.
+func (c *Client) RateBudget(category RateCategory) (budget RateBudget, ok bool) {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	budget, ok = c.limiter.budgets[category]
+	if ok && !time.Now().Before(budget.Reset) {
+		return RateBudget{}, false
+	}
+	return budget, ok
+}
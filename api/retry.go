@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides how often and how long apart a request that failed
+// with a transient error is attempted again. Idempotent requests are retried
+// according to the policy; requests that change orders only if
+// RetryMutations is set.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles with every
+	// further attempt, up to MaxDelay, and is randomized by up to half to
+	// keep clients from retrying in lockstep.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryableStatus lists the response status codes worth retrying.
+	RetryableStatus []int
+	// RetryMutations also retries requests that place, replace or cancel
+	// orders. A mutation that failed without a response may have reached
+	// the server, so a retry can act twice.
+	RetryMutations bool
+}
+
+// DefaultRetryPolicy is used by clients whose ClientOptions.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	BaseDelay:       500 * time.Millisecond,
+	MaxDelay:        10 * time.Second,
+	RetryableStatus: []int{500, 502, 503, 504},
+}
+
+func (c *Client) retryPolicy() *RetryPolicy {
+	if c.Options.Retry != nil {
+		return c.Options.Retry
+	}
+	return &DefaultRetryPolicy
+}
+
+// retries reports whether a request is retried under the policy.
+func (p *RetryPolicy) retries(idempotent bool) bool {
+	return p.MaxAttempts > 1 && (idempotent || p.RetryMutations)
+}
+
+func (p *RetryPolicy) retryableStatus(statusCode int) bool {
+	for _, s := range p.RetryableStatus {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retry number attempt, counting from 1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retryAfter returns the wait asked for by the Retry-After header, in
+// seconds or as an HTTP date, or 0 if there is none.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(value); err == nil {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// retryableError reports whether err is a transport failure, such as a
+// reset connection or a timeout, that another attempt may not run into.
+// Errors caused by ctx ending are not retried.
+func retryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
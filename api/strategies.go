@@ -0,0 +1,471 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BracketComponent is one order of a bracket: the entry (OrderClassPrimary),
+// the profit target (OrderClassLimit) or the stop loss (OrderClassStopLoss).
+type BracketComponent struct {
+	OrderId     int         `json:"orderId"`
+	Quantity    float64     `json:"quantity"`
+	Action      OrderSide   `json:"action"`
+	LimitPrice  float64     `json:"limitPrice,omitempty"`
+	StopPrice   float64     `json:"stopPrice,omitempty"`
+	OrderType   OrderType   `json:"orderType"`
+	TimeInForce TimeInForce `json:"timeInForce"`
+	OrderClass  OrderClass  `json:"orderClass"`
+}
+
+// BracketOrder is an entry order with exit orders attached to it. The exits
+// only become active once the entry fills, and filling one cancels the other.
+type BracketOrder struct {
+	AccountNumber  string             `json:"accountNumber"`
+	SymbolId       int                `json:"symbolId"`
+	PrimaryRoute   string             `json:"primaryRoute"`
+	SecondaryRoute string             `json:"secondaryRoute"`
+	Components     []BracketComponent `json:"components"`
+}
+
+// NewBracketOrder starts a bracket whose entry is a limit order, or a market
+// order if limitPrice is 0. Add exits with WithProfitTarget and WithStopLoss.
+func NewBracketOrder(accountNumber string, symbolId int, action OrderSide, quantity float64, limitPrice float64) *BracketOrder {
+	entry := BracketComponent{
+		Quantity:    quantity,
+		Action:      action,
+		LimitPrice:  limitPrice,
+		OrderType:   OrderTypeLimit,
+		TimeInForce: TimeInForceDay,
+		OrderClass:  OrderClassPrimary,
+	}
+	if limitPrice == 0 {
+		entry.OrderType = OrderTypeMarket
+	}
+	return &BracketOrder{
+		AccountNumber:  accountNumber,
+		SymbolId:       symbolId,
+		PrimaryRoute:   DefaultRoute,
+		SecondaryRoute: DefaultRoute,
+		Components:     []BracketComponent{entry},
+	}
+}
+
+// exitSide is the side that closes a position opened with side.
+func exitSide(side OrderSide) OrderSide {
+	if buying(side) {
+		return OrderSideSell
+	}
+	return OrderSideBuy
+}
+
+func buying(side OrderSide) bool {
+	switch side {
+	case OrderSideBuy, OrderSideCover, OrderSideBTO, OrderSideBTC:
+		return true
+	}
+	return false
+}
+
+func (b *BracketOrder) entry() BracketComponent {
+	return b.Components[0]
+}
+
+// WithProfitTarget adds a limit exit at limitPrice, good till canceled.
+func (b *BracketOrder) WithProfitTarget(limitPrice float64) *BracketOrder {
+	e := b.entry()
+	b.Components = append(b.Components, BracketComponent{
+		Quantity:    e.Quantity,
+		Action:      exitSide(e.Action),
+		LimitPrice:  limitPrice,
+		OrderType:   OrderTypeLimit,
+		TimeInForce: TimeInForceGoodTillCanceled,
+		OrderClass:  OrderClassLimit,
+	})
+	return b
+}
+
+// WithStopLoss adds a stop exit triggered at stopPrice, good till canceled.
+func (b *BracketOrder) WithStopLoss(stopPrice float64) *BracketOrder {
+	e := b.entry()
+	b.Components = append(b.Components, BracketComponent{
+		Quantity:    e.Quantity,
+		Action:      exitSide(e.Action),
+		StopPrice:   stopPrice,
+		OrderType:   OrderTypeStop,
+		TimeInForce: TimeInForceGoodTillCanceled,
+		OrderClass:  OrderClassStopLoss,
+	})
+	return b
+}
+
+// Validate checks that the bracket has one entry and one or both of a profit
+// target and a stop loss on the opposite side, that the exits have prices,
+// that the profit target and stop loss sit on the right side of a limit
+// entry, and that the stop loss is below the profit target of a long
+// bracket and above it for a short one.
+func (b *BracketOrder) Validate() error {
+	if b.AccountNumber == "" || b.SymbolId == 0 {
+		return invalidOrder("bracket needs an account number and a symbol id")
+	}
+	if len(b.Components) < 2 || b.Components[0].OrderClass != OrderClassPrimary {
+		return invalidOrder("bracket needs an entry followed by at least one exit")
+	}
+	e := b.entry()
+	if e.Quantity <= 0 {
+		return invalidOrder("quantity must be positive, got %v", e.Quantity)
+	}
+	long := buying(e.Action)
+	exits := map[OrderClass]BracketComponent{}
+	for _, c := range b.Components[1:] {
+		// Every exit covers the whole entry, so a second one of a kind
+		// would close the position twice.
+		if _, ok := exits[c.OrderClass]; ok {
+			return invalidOrder("bracket can only have one %v component", c.OrderClass)
+		}
+		exits[c.OrderClass] = c
+		if buying(c.Action) == long {
+			return invalidOrder("%v exit must be on the opposite side of the %v entry", c.OrderClass, e.Action)
+		}
+		if c.Quantity != e.Quantity {
+			return invalidOrder("%v exit quantity %v does not match entry quantity %v", c.OrderClass, c.Quantity, e.Quantity)
+		}
+		switch c.OrderClass {
+		case OrderClassLimit:
+			if c.LimitPrice <= 0 {
+				return invalidOrder("profit target needs a limit price")
+			}
+			if e.LimitPrice > 0 && (c.LimitPrice <= e.LimitPrice) == long {
+				return invalidOrder("profit target %v is on the wrong side of entry %v", c.LimitPrice, e.LimitPrice)
+			}
+		case OrderClassStopLoss:
+			if c.StopPrice <= 0 {
+				return invalidOrder("stop loss needs a stop price")
+			}
+			if e.LimitPrice > 0 && (c.StopPrice >= e.LimitPrice) == long {
+				return invalidOrder("stop loss %v is on the wrong side of entry %v", c.StopPrice, e.LimitPrice)
+			}
+		case OrderClassPrimary:
+			return invalidOrder("bracket can only have one entry")
+		default:
+			return invalidOrder("unknown order class %q", c.OrderClass)
+		}
+	}
+	target, hasTarget := exits[OrderClassLimit]
+	stop, hasStop := exits[OrderClassStopLoss]
+	// A market entry has no price to compare against, but the exits still
+	// have to bracket the position.
+	if hasTarget && hasStop && (stop.StopPrice >= target.LimitPrice) == long {
+		return invalidOrder("stop loss %v is on the wrong side of profit target %v", stop.StopPrice, target.LimitPrice)
+	}
+	return nil
+}
+
+// BracketOrderImpact previews b without placing it.
+func (c *Client) BracketOrderImpact(ctx context.Context, b *BracketOrder) (*OrderImpact, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	result := &OrderImpact{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/bracket/impact", b.AccountNumber)
+	if err := c.queryJSON(ctx, endpoint, b, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PlaceBracketOrder submits b, previewing it first like PlaceOrder.
+func (c *Client) PlaceBracketOrder(ctx context.Context, b *BracketOrder) (*OrderResult, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	impact, err := c.preview(ctx, b, func() (*OrderImpact, error) { return c.BracketOrderImpact(ctx, b) })
+	if err != nil {
+		return nil, err
+	}
+	result := &OrderResult{Impact: impact}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/bracket", b.AccountNumber)
+	if err = c.postJSON(ctx, endpoint, b, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type StrategyType string
+
+const (
+	StrategyCoveredCall        StrategyType = "CoveredCall"
+	StrategyMarriedPuts        StrategyType = "MarriedPuts"
+	StrategyVerticalCallSpread StrategyType = "VerticalCallSpread"
+	StrategyVerticalPutSpread  StrategyType = "VerticalPutSpread"
+	StrategyCalendarCallSpread StrategyType = "CalendarCallSpread"
+	StrategyCalendarPutSpread  StrategyType = "CalendarPutSpread"
+	StrategyDiagonalCallSpread StrategyType = "DiagonalCallSpread"
+	StrategyDiagonalPutSpread  StrategyType = "DiagonalPutSpread"
+	StrategyCollar             StrategyType = "Collar"
+	StrategyStraddle           StrategyType = "Straddle"
+	StrategyStrangle           StrategyType = "Strangle"
+	StrategyButterflyCall      StrategyType = "ButterflyCall"
+	StrategyButterflyPut       StrategyType = "ButterflyPut"
+	StrategyCustom             StrategyType = "Custom"
+)
+
+// StrategyLeg is one leg of a strategy order. OptionType, Strike and Expiry
+// are only used to validate the strategy and are not sent; OptionType is
+// empty for a stock leg.
+type StrategyLeg struct {
+	SymbolId         int        `json:"symbolId"`
+	Action           OrderSide  `json:"action"`
+	LegRatioQuantity int        `json:"legRatioQuantity"`
+	OptionType       OptionType `json:"-"`
+	Strike           float64    `json:"-"`
+	Expiry           time.Time  `json:"-"`
+}
+
+// StrategyOrder is a multi-leg order filled as a whole at a net LimitPrice.
+type StrategyOrder struct {
+	AccountNumber  string        `json:"accountNumber"`
+	SymbolId       int           `json:"symbolId"`
+	StrategyType   StrategyType  `json:"strategyType"`
+	OrderType      OrderType     `json:"orderType"`
+	TimeInForce    TimeInForce   `json:"timeInForce"`
+	LimitPrice     float64       `json:"limitPrice,omitempty"`
+	Quantity       float64       `json:"quantity"`
+	PrimaryRoute   string        `json:"primaryRoute"`
+	SecondaryRoute string        `json:"secondaryRoute"`
+	Legs           []StrategyLeg `json:"legs"`
+}
+
+// NewStrategyOrder starts a day limit strategy order on the underlying
+// symbol. Add legs with AddStockLeg and AddOptionLeg.
+func NewStrategyOrder(accountNumber string, underlyingId int, strategy StrategyType, quantity float64, limitPrice float64) *StrategyOrder {
+	return &StrategyOrder{
+		AccountNumber:  accountNumber,
+		SymbolId:       underlyingId,
+		StrategyType:   strategy,
+		OrderType:      OrderTypeLimit,
+		TimeInForce:    TimeInForceDay,
+		LimitPrice:     limitPrice,
+		Quantity:       quantity,
+		PrimaryRoute:   DefaultRoute,
+		SecondaryRoute: DefaultRoute,
+	}
+}
+
+// AddStockLeg adds a leg on the underlying stock. ratio is in shares per strategy unit.
+func (s *StrategyOrder) AddStockLeg(symbolId int, action OrderSide, ratio int) *StrategyOrder {
+	s.Legs = append(s.Legs, StrategyLeg{SymbolId: symbolId, Action: action, LegRatioQuantity: ratio})
+	return s
+}
+
+// AddOptionLeg adds a leg on the option contract described by symbol.
+func (s *StrategyOrder) AddOptionLeg(symbol *Symbol, action OrderSide, ratio int) *StrategyOrder {
+	s.Legs = append(s.Legs, StrategyLeg{
+		SymbolId:         symbol.SymbolId,
+		Action:           action,
+		LegRatioQuantity: ratio,
+		OptionType:       symbol.OptionType,
+		Strike:           symbol.OptionStrikePrice,
+		Expiry:           symbol.OptionExpiryDate.Time,
+	})
+	return s
+}
+
+// legSet sorts the legs of a strategy for validation.
+type legSet struct {
+	stock []StrategyLeg
+	calls []StrategyLeg
+	puts  []StrategyLeg
+}
+
+func groupLegs(legs []StrategyLeg) legSet {
+	var set legSet
+	for _, l := range legs {
+		switch l.OptionType {
+		case OptionTypeCall:
+			set.calls = append(set.calls, l)
+		case OptionTypePut:
+			set.puts = append(set.puts, l)
+		default:
+			set.stock = append(set.stock, l)
+		}
+	}
+	byStrike := func(legs []StrategyLeg) {
+		sort.Slice(legs, func(i, j int) bool { return legs[i].Strike < legs[j].Strike })
+	}
+	byStrike(set.calls)
+	byStrike(set.puts)
+	return set
+}
+
+func (s legSet) count(stock, calls, puts int) bool {
+	return len(s.stock) == stock && len(s.calls) == calls && len(s.puts) == puts
+}
+
+// spread validates a two-leg spread: one leg bought, one sold, same ratio,
+// and strikes and expiries equal or different as the spread requires.
+func spread(legs []StrategyLeg, sameStrike, sameExpiry bool) error {
+	a, b := legs[0], legs[1]
+	if buying(a.Action) == buying(b.Action) {
+		return invalidOrder("spread needs one leg bought and one sold")
+	}
+	if a.LegRatioQuantity != b.LegRatioQuantity {
+		return invalidOrder("spread legs need the same ratio")
+	}
+	if (a.Strike == b.Strike) != sameStrike {
+		return invalidOrder("spread strikes must be %v", sameOrDifferent(sameStrike))
+	}
+	if a.Expiry.Equal(b.Expiry) != sameExpiry {
+		return invalidOrder("spread expiries must be %v", sameOrDifferent(sameExpiry))
+	}
+	return nil
+}
+
+func sameOrDifferent(same bool) string {
+	if same {
+		return "the same"
+	}
+	return "different"
+}
+
+// sharesPerContract is how many shares of the underlying one option contract covers.
+const sharesPerContract = 100
+
+// covered validates that the stock leg holds exactly the shares the option
+// legs cover: its ratio, in shares, is sharesPerContract times theirs.
+func covered(stock StrategyLeg, options ...StrategyLeg) error {
+	for _, o := range options {
+		if stock.LegRatioQuantity != sharesPerContract*o.LegRatioQuantity {
+			return invalidOrder("stock leg ratio %v does not cover option leg ratio %v at %v shares per contract",
+				stock.LegRatioQuantity, o.LegRatioQuantity, sharesPerContract)
+		}
+	}
+	return nil
+}
+
+// butterfly validates three legs of one option type at ascending strikes in
+// a 1:2:1 ratio, with the body on the opposite side of the wings.
+func butterfly(legs []StrategyLeg) error {
+	low, body, high := legs[0], legs[1], legs[2]
+	if !(low.Strike < body.Strike && body.Strike < high.Strike) {
+		return invalidOrder("butterfly needs three different strikes")
+	}
+	if low.LegRatioQuantity != high.LegRatioQuantity || body.LegRatioQuantity != 2*low.LegRatioQuantity {
+		return invalidOrder("butterfly legs need a 1:2:1 ratio")
+	}
+	if buying(low.Action) != buying(high.Action) || buying(low.Action) == buying(body.Action) {
+		return invalidOrder("butterfly wings must be on the opposite side of the body")
+	}
+	if !low.Expiry.Equal(body.Expiry) || !body.Expiry.Equal(high.Expiry) {
+		return invalidOrder("butterfly legs must share an expiry")
+	}
+	return nil
+}
+
+// Validate checks the legs against the shape of StrategyType: how many stock,
+// call and put legs it has, their sides and their ratios.
+func (s *StrategyOrder) Validate() error {
+	if s.AccountNumber == "" || s.SymbolId == 0 {
+		return invalidOrder("strategy needs an account number and an underlying symbol id")
+	}
+	if s.Quantity <= 0 {
+		return invalidOrder("quantity must be positive, got %v", s.Quantity)
+	}
+	if s.OrderType == OrderTypeLimit && s.LimitPrice == 0 {
+		return invalidOrder("limit strategy order needs a limit price")
+	}
+	if len(s.Legs) < 2 {
+		return invalidOrder("strategy needs at least two legs")
+	}
+	for _, l := range s.Legs {
+		if l.SymbolId == 0 || l.Action == "" || l.LegRatioQuantity <= 0 {
+			return invalidOrder("every leg needs a symbol id, an action and a positive ratio")
+		}
+	}
+	legs := groupLegs(s.Legs)
+	wrongLegs := invalidOrder("%v has the wrong legs", s.StrategyType)
+	switch s.StrategyType {
+	case StrategyCoveredCall:
+		if !legs.count(1, 1, 0) || !buying(legs.stock[0].Action) || buying(legs.calls[0].Action) {
+			return wrongLegs
+		}
+		return covered(legs.stock[0], legs.calls[0])
+	case StrategyMarriedPuts:
+		if !legs.count(1, 0, 1) || !buying(legs.stock[0].Action) || !buying(legs.puts[0].Action) {
+			return wrongLegs
+		}
+		return covered(legs.stock[0], legs.puts[0])
+	case StrategyCollar:
+		if !legs.count(1, 1, 1) || !buying(legs.stock[0].Action) || !buying(legs.puts[0].Action) || buying(legs.calls[0].Action) {
+			return wrongLegs
+		}
+		return covered(legs.stock[0], legs.calls[0], legs.puts[0])
+	case StrategyVerticalCallSpread, StrategyCalendarCallSpread, StrategyDiagonalCallSpread:
+		if !legs.count(0, 2, 0) {
+			return wrongLegs
+		}
+		return spread(legs.calls, s.StrategyType == StrategyCalendarCallSpread, s.StrategyType == StrategyVerticalCallSpread)
+	case StrategyVerticalPutSpread, StrategyCalendarPutSpread, StrategyDiagonalPutSpread:
+		if !legs.count(0, 0, 2) {
+			return wrongLegs
+		}
+		return spread(legs.puts, s.StrategyType == StrategyCalendarPutSpread, s.StrategyType == StrategyVerticalPutSpread)
+	case StrategyStraddle, StrategyStrangle:
+		if !legs.count(0, 1, 1) {
+			return wrongLegs
+		}
+		call, put := legs.calls[0], legs.puts[0]
+		if buying(call.Action) != buying(put.Action) || call.LegRatioQuantity != put.LegRatioQuantity || !call.Expiry.Equal(put.Expiry) {
+			return invalidOrder("%v legs must share side, ratio and expiry", s.StrategyType)
+		}
+		if (call.Strike == put.Strike) != (s.StrategyType == StrategyStraddle) {
+			return invalidOrder("straddle strikes must match and strangle strikes must differ")
+		}
+	case StrategyButterflyCall:
+		if !legs.count(0, 3, 0) {
+			return wrongLegs
+		}
+		return butterfly(legs.calls)
+	case StrategyButterflyPut:
+		if !legs.count(0, 0, 3) {
+			return wrongLegs
+		}
+		return butterfly(legs.puts)
+	case StrategyCustom:
+	default:
+		return invalidOrder("unknown strategy type %q", s.StrategyType)
+	}
+	return nil
+}
+
+// StrategyOrderImpact previews s without placing it.
+func (c *Client) StrategyOrderImpact(ctx context.Context, s *StrategyOrder) (*OrderImpact, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	result := &OrderImpact{}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/strategy/impact", s.AccountNumber)
+	if err := c.queryJSON(ctx, endpoint, s, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PlaceStrategyOrder submits s, previewing it first like PlaceOrder.
+func (c *Client) PlaceStrategyOrder(ctx context.Context, s *StrategyOrder) (*OrderResult, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	impact, err := c.preview(ctx, s, func() (*OrderImpact, error) { return c.StrategyOrderImpact(ctx, s) })
+	if err != nil {
+		return nil, err
+	}
+	result := &OrderResult{Impact: impact}
+	endpoint := fmt.Sprintf("v1/accounts/%v/orders/strategy", s.AccountNumber)
+	if err = c.postJSON(ctx, endpoint, s, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
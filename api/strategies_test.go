@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBracketOrderValidate(t *testing.T) {
+	buy := func() *BracketOrder { return NewBracketOrder("11111111", 9292, OrderSideBuy, 10, 100) }
+	sell := func() *BracketOrder { return NewBracketOrder("11111111", 9292, OrderSideSell, 10, 100) }
+	tests := []struct {
+		name  string
+		order *BracketOrder
+		valid bool
+	}{
+		{"buy with both exits", buy().WithProfitTarget(110).WithStopLoss(95), true},
+		{"buy with profit target", buy().WithProfitTarget(110), true},
+		{"buy with stop loss", buy().WithStopLoss(95), true},
+		{"sell with both exits", sell().WithProfitTarget(90).WithStopLoss(105), true},
+		{"market entry", NewBracketOrder("11111111", 9292, OrderSideBuy, 10, 0).WithProfitTarget(110).WithStopLoss(95), true},
+		{"no exit", buy(), false},
+		{"buy with profit target below entry", buy().WithProfitTarget(90), false},
+		{"buy with stop loss above entry", buy().WithStopLoss(105), false},
+		{"sell with profit target above entry", sell().WithProfitTarget(110), false},
+		{"sell with stop loss below entry", sell().WithStopLoss(95), false},
+		{"two profit targets", buy().WithProfitTarget(110).WithProfitTarget(120), false},
+		{"two stop losses", buy().WithStopLoss(95).WithStopLoss(90), false},
+		{"two of each", buy().WithProfitTarget(110).WithStopLoss(95).WithProfitTarget(120).WithStopLoss(90), false},
+		{"exit on the entry side", func() *BracketOrder {
+			b := buy().WithProfitTarget(110)
+			b.Components[1].Action = OrderSideBuy
+			return b
+		}(), false},
+		{"exit quantity differs", func() *BracketOrder {
+			b := buy().WithStopLoss(95)
+			b.Components[1].Quantity = 5
+			return b
+		}(), false},
+		{"second entry", func() *BracketOrder {
+			b := buy().WithProfitTarget(110)
+			b.Components = append(b.Components, b.Components[0])
+			return b
+		}(), false},
+		{"no entry", func() *BracketOrder {
+			b := buy().WithProfitTarget(110).WithStopLoss(95)
+			b.Components = b.Components[1:]
+			return b
+		}(), false},
+		{"stop loss without price", buy().WithStopLoss(0), false},
+		{"profit target without price", sell().WithProfitTarget(0), false},
+		{"market entry stop loss without price", NewBracketOrder("11111111", 9292, OrderSideBuy, 10, 0).WithStopLoss(0), false},
+		{"market entry with stop loss above profit target", NewBracketOrder("11111111", 9292, OrderSideBuy, 10, 0).WithProfitTarget(95).WithStopLoss(110), false},
+		{"short market entry with stop loss below profit target", NewBracketOrder("11111111", 9292, OrderSideSell, 10, 0).WithProfitTarget(105).WithStopLoss(90), false},
+		{"short market entry", NewBracketOrder("11111111", 9292, OrderSideSell, 10, 0).WithProfitTarget(90).WithStopLoss(105), true},
+		{"unknown order class", func() *BracketOrder {
+			b := buy().WithProfitTarget(110)
+			b.Components[1].OrderClass = "Trailing"
+			return b
+		}(), false},
+		{"zero quantity", NewBracketOrder("11111111", 9292, OrderSideBuy, 0, 100).WithProfitTarget(110), false},
+		{"no account", NewBracketOrder("", 9292, OrderSideBuy, 10, 100).WithProfitTarget(110), false},
+		{"no symbol", NewBracketOrder("11111111", 0, OrderSideBuy, 10, 100).WithProfitTarget(110), false},
+	}
+	for _, tt := range tests {
+		err := tt.order.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("%v: got %v, want ErrInvalidOrder", tt.name, err)
+		}
+	}
+}
+
+func TestStrategyOrderValidate(t *testing.T) {
+	const stock = 8049
+	near := time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC)
+	far := near.AddDate(0, 1, 0)
+	option := func(id int, optionType OptionType, strike float64, expiry time.Time) *Symbol {
+		return &Symbol{SymbolId: id, OptionType: optionType, OptionStrikePrice: strike, OptionExpiryDate: Time{Time: expiry}}
+	}
+	call := func(strike float64, expiry time.Time) *Symbol {
+		return option(int(strike)*10+1, OptionTypeCall, strike, expiry)
+	}
+	put := func(strike float64, expiry time.Time) *Symbol {
+		return option(int(strike)*10+2, OptionTypePut, strike, expiry)
+	}
+	strategy := func(s StrategyType) *StrategyOrder {
+		return NewStrategyOrder("11111111", stock, s, 1, 1.25)
+	}
+	tests := []struct {
+		name  string
+		order *StrategyOrder
+		valid bool
+	}{
+		{"covered call", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(call(110, near), OrderSideSTO, 1), true},
+		{"covered call bought", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(call(110, near), OrderSideBTO, 1), false},
+		{"covered call one share per contract", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+		{"covered call two contracts", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 200).AddOptionLeg(call(110, near), OrderSideSTO, 2), true},
+		{"covered call uncovered contract", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(call(110, near), OrderSideSTO, 2), false},
+		{"covered call with put", strategy(StrategyCoveredCall).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(110, near), OrderSideSTO, 1), false},
+
+		{"married puts", strategy(StrategyMarriedPuts).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(90, near), OrderSideBTO, 1), true},
+		{"married puts too many shares", strategy(StrategyMarriedPuts).AddStockLeg(stock, OrderSideBuy, 150).AddOptionLeg(put(90, near), OrderSideBTO, 1), false},
+		{"married puts sold", strategy(StrategyMarriedPuts).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+		{"married puts shorting stock", strategy(StrategyMarriedPuts).AddStockLeg(stock, OrderSideShort, 100).AddOptionLeg(put(90, near), OrderSideBTO, 1), false},
+
+		{"collar", strategy(StrategyCollar).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(90, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), true},
+		{"collar ratio mismatch", strategy(StrategyCollar).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(90, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 2), false},
+		{"collar one share per contract", strategy(StrategyCollar).AddStockLeg(stock, OrderSideBuy, 1).AddOptionLeg(put(90, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+		{"collar buying the call", strategy(StrategyCollar).AddStockLeg(stock, OrderSideBuy, 100).AddOptionLeg(put(90, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideBTO, 1), false},
+		{"collar without stock", strategy(StrategyCollar).AddOptionLeg(put(90, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+
+		{"vertical call spread", strategy(StrategyVerticalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), true},
+		{"vertical call spread same strike", strategy(StrategyVerticalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypeCall, 100, near), OrderSideSTO, 1), false},
+		{"vertical call spread across expiries", strategy(StrategyVerticalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, far), OrderSideSTO, 1), false},
+		{"vertical call spread both bought", strategy(StrategyVerticalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideBTO, 1), false},
+		{"vertical call spread ratio", strategy(StrategyVerticalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 2), false},
+		{"vertical call spread on puts", strategy(StrategyVerticalCallSpread).AddOptionLeg(put(100, near), OrderSideBTO, 1).AddOptionLeg(put(110, near), OrderSideSTO, 1), false},
+		{"vertical put spread", strategy(StrategyVerticalPutSpread).AddOptionLeg(put(110, near), OrderSideBTO, 1).AddOptionLeg(put(100, near), OrderSideSTO, 1), true},
+		{"vertical put spread same strike", strategy(StrategyVerticalPutSpread).AddOptionLeg(put(100, near), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypePut, 100, near), OrderSideSTO, 1), false},
+
+		{"calendar call spread", strategy(StrategyCalendarCallSpread).AddOptionLeg(call(100, far), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypeCall, 100, near), OrderSideSTO, 1), true},
+		{"calendar call spread different strikes", strategy(StrategyCalendarCallSpread).AddOptionLeg(call(100, far), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+		{"calendar call spread same expiry", strategy(StrategyCalendarCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypeCall, 100, near), OrderSideSTO, 1), false},
+		{"calendar put spread", strategy(StrategyCalendarPutSpread).AddOptionLeg(put(100, far), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypePut, 100, near), OrderSideSTO, 1), true},
+		{"calendar put spread same expiry", strategy(StrategyCalendarPutSpread).AddOptionLeg(put(100, near), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypePut, 100, near), OrderSideSTO, 1), false},
+
+		{"diagonal call spread", strategy(StrategyDiagonalCallSpread).AddOptionLeg(call(100, far), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), true},
+		{"diagonal call spread same expiry", strategy(StrategyDiagonalCallSpread).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+		{"diagonal put spread", strategy(StrategyDiagonalPutSpread).AddOptionLeg(put(110, far), OrderSideBTO, 1).AddOptionLeg(put(100, near), OrderSideSTO, 1), true},
+		{"diagonal put spread same strike", strategy(StrategyDiagonalPutSpread).AddOptionLeg(put(100, far), OrderSideBTO, 1).AddOptionLeg(option(9, OptionTypePut, 100, near), OrderSideSTO, 1), false},
+
+		{"straddle", strategy(StrategyStraddle).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(100, near), OrderSideBTO, 1), true},
+		{"straddle different strikes", strategy(StrategyStraddle).AddOptionLeg(call(110, near), OrderSideBTO, 1).AddOptionLeg(put(90, near), OrderSideBTO, 1), false},
+		{"straddle opposite sides", strategy(StrategyStraddle).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(100, near), OrderSideSTO, 1), false},
+		{"straddle across expiries", strategy(StrategyStraddle).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(100, far), OrderSideBTO, 1), false},
+		{"strangle", strategy(StrategyStrangle).AddOptionLeg(call(110, near), OrderSideSTO, 1).AddOptionLeg(put(90, near), OrderSideSTO, 1), true},
+		{"strangle same strike", strategy(StrategyStrangle).AddOptionLeg(call(100, near), OrderSideSTO, 1).AddOptionLeg(put(100, near), OrderSideSTO, 1), false},
+		{"strangle ratio", strategy(StrategyStrangle).AddOptionLeg(call(110, near), OrderSideSTO, 1).AddOptionLeg(put(90, near), OrderSideSTO, 2), false},
+		{"strangle with two calls", strategy(StrategyStrangle).AddOptionLeg(call(110, near), OrderSideSTO, 1).AddOptionLeg(call(90, near), OrderSideSTO, 1), false},
+
+		{"butterfly call", strategy(StrategyButterflyCall).AddOptionLeg(call(90, near), OrderSideBTO, 1).AddOptionLeg(call(100, near), OrderSideSTO, 2).AddOptionLeg(call(110, near), OrderSideBTO, 1), true},
+		{"butterfly call ratio", strategy(StrategyButterflyCall).AddOptionLeg(call(90, near), OrderSideBTO, 1).AddOptionLeg(call(100, near), OrderSideSTO, 1).AddOptionLeg(call(110, near), OrderSideBTO, 1), false},
+		{"butterfly call body on wing side", strategy(StrategyButterflyCall).AddOptionLeg(call(90, near), OrderSideBTO, 1).AddOptionLeg(call(100, near), OrderSideBTO, 2).AddOptionLeg(call(110, near), OrderSideBTO, 1), false},
+		{"butterfly call repeated strike", strategy(StrategyButterflyCall).AddOptionLeg(call(90, near), OrderSideBTO, 1).AddOptionLeg(call(100, near), OrderSideSTO, 2).AddOptionLeg(option(9, OptionTypeCall, 100, near), OrderSideBTO, 1), false},
+		{"butterfly call across expiries", strategy(StrategyButterflyCall).AddOptionLeg(call(90, near), OrderSideBTO, 1).AddOptionLeg(call(100, near), OrderSideSTO, 2).AddOptionLeg(call(110, far), OrderSideBTO, 1), false},
+		{"butterfly put", strategy(StrategyButterflyPut).AddOptionLeg(put(110, near), OrderSideSTO, 1).AddOptionLeg(put(100, near), OrderSideBTO, 2).AddOptionLeg(put(90, near), OrderSideSTO, 1), true},
+		{"butterfly put with a call", strategy(StrategyButterflyPut).AddOptionLeg(put(110, near), OrderSideSTO, 1).AddOptionLeg(put(100, near), OrderSideBTO, 2).AddOptionLeg(call(90, near), OrderSideSTO, 1), false},
+
+		{"custom", strategy(StrategyCustom).AddOptionLeg(call(100, near), OrderSideBTO, 3).AddOptionLeg(put(90, far), OrderSideSTO, 1), true},
+		{"unknown strategy", strategy("IronCondor").AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(call(110, near), OrderSideSTO, 1), false},
+
+		{"single leg", strategy(StrategyCustom).AddOptionLeg(call(100, near), OrderSideBTO, 1), false},
+		{"leg without ratio", strategy(StrategyCustom).AddOptionLeg(call(100, near), OrderSideBTO, 0).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+		{"leg without action", strategy(StrategyCustom).AddOptionLeg(call(100, near), "", 1).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+		{"no limit price", NewStrategyOrder("11111111", stock, StrategyCustom, 1, 0).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+		{"zero quantity", NewStrategyOrder("11111111", stock, StrategyCustom, 0, 1.25).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+		{"no underlying", NewStrategyOrder("11111111", 0, StrategyCustom, 1, 1.25).AddOptionLeg(call(100, near), OrderSideBTO, 1).AddOptionLeg(put(90, near), OrderSideSTO, 1), false},
+	}
+	for _, tt := range tests {
+		err := tt.order.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("%v: got %v, want ErrInvalidOrder", tt.name, err)
+		}
+	}
+}
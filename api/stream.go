@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	streamDialTimeout = 30 * time.Second
+	// maxStreamBackoff caps the wait between reconnect attempts.
+	maxStreamBackoff = 30 * time.Second
+)
+
+type streamPortResponse struct {
+	StreamPort int `json:"streamPort"`
+}
+
+type streamAck struct {
+	Success bool   `json:"success"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// streamer keeps one Questrade WebSocket stream alive. It asks endpoint for a
+// stream port, authenticates with the access token, passes every message to
+// handle and reconnects, asking for a new port, whenever the connection
+// drops or the access token is about to expire.
+type streamer struct {
+	client   *Client
+	endpoint string
+	params   map[string]string
+	handle   func(message []byte)
+	// reconnected is called after every successful reconnect.
+	reconnected func()
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	errs      chan error
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	conn      *wsConn
+}
+
+func newStreamer(ctx context.Context, c *Client, endpoint string, params map[string]string, handle func([]byte)) *streamer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &streamer{
+		ctx:      ctx,
+		cancel:   cancel,
+		client:   c,
+		endpoint: endpoint,
+		params:   params,
+		handle:   handle,
+		errs:     make(chan error, 16),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// streamURL builds the WebSocket URL of port on the API server. A plain http
+// API server, such as a local stand-in, gets a plain ws URL.
+func (c *Client) streamURL(port int) (string, error) {
+	u, err := url.Parse(c.apiServer())
+	if err != nil {
+		return "", err
+	}
+	scheme := "wss"
+	if u.Scheme == "http" {
+		scheme = "ws"
+	}
+	return fmt.Sprintf("%s://%s/", scheme, u.Hostname()+":"+strconv.Itoa(port)), nil
+}
+
+// start connects for the first time and keeps the stream running in the
+// background until it is closed or its context is done.
+func (s *streamer) start() error {
+	ws, err := s.connect()
+	if err != nil {
+		s.cancel()
+		return err
+	}
+	go s.run(ws)
+	go func() {
+		<-s.ctx.Done()
+		s.stop()
+	}()
+	return nil
+}
+
+// connect opens and authenticates a new connection. A rejected access token
+// is refreshed and the connection attempted once more.
+func (s *streamer) connect() (*wsConn, error) {
+	ws, token, err := s.dial()
+	if errors.Is(err, ErrUnauthorized) {
+		if err = s.client.refresh(s.ctx, token); err != nil {
+			return nil, err
+		}
+		ws, _, err = s.dial()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		ws.Close()
+		return nil, errWebSocketClosed
+	default:
+	}
+	s.conn = ws
+	return ws, nil
+}
+
+func (s *streamer) dial() (*wsConn, string, error) {
+	port := &streamPortResponse{}
+	if err := s.client.getJSON(s.ctx, s.endpoint, s.params, port); err != nil {
+		return nil, "", err
+	}
+	u, err := s.client.streamURL(port.StreamPort)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := s.client.accessToken(s.ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	ws, err := dialWebSocket(s.ctx, u, streamDialTimeout)
+	if err != nil {
+		return nil, token, err
+	}
+	if err = ws.WriteText([]byte(token)); err != nil {
+		ws.Close()
+		return nil, token, err
+	}
+	message, err := ws.ReadMessage()
+	if err != nil {
+		ws.Close()
+		return nil, token, err
+	}
+	ack := &streamAck{}
+	if err = json.Unmarshal(message, ack); err != nil || !ack.Success {
+		ws.Close()
+		return nil, token, &ApiError{StatusCode: 401, Code: ack.Code, Message: ack.Message, Endpoint: u, sentinel: ErrUnauthorized}
+	}
+	return ws, token, nil
+}
+
+func (s *streamer) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// report passes a recoverable error to the owner of the stream without blocking.
+func (s *streamer) report(err error) {
+	select {
+	case s.errs <- err:
+	default:
+		log.Printf("%v stream: %v\n", s.endpoint, err)
+	}
+}
+
+// read hands messages to handle until the connection fails. The read
+// deadline is set to when the access token needs refreshing, so the stream
+// reconnects with a fresh token before the server drops it.
+func (s *streamer) read(ws *wsConn) error {
+	s.client.mu.Lock()
+	expiresAt := s.client.Session.ExpiresAt
+	s.client.mu.Unlock()
+	if !expiresAt.IsZero() {
+		ws.SetReadDeadline(expiresAt.Add(-expiryMargin))
+	}
+	for {
+		message, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		s.handle(message)
+	}
+}
+
+func (s *streamer) run(ws *wsConn) {
+	defer close(s.stopped)
+	backoff := time.Second
+	for {
+		err := s.read(ws)
+		ws.Close()
+		if s.closed() {
+			return
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			s.report(err)
+		}
+		for {
+			ws, err = s.connect()
+			if err == nil {
+				break
+			}
+			if s.closed() {
+				return
+			}
+			s.report(err)
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				return
+			}
+			if backoff *= 2; backoff > maxStreamBackoff {
+				backoff = maxStreamBackoff
+			}
+		}
+		backoff = time.Second
+		if s.reconnected != nil {
+			s.reconnected()
+		}
+	}
+}
+
+// stop signals the stream to shut down and drops its connection.
+func (s *streamer) stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.cancel()
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+}
+
+// close stops the stream and waits for its goroutine to finish.
+func (s *streamer) close() {
+	s.stop()
+	<-s.stopped
+}
+
+// QuoteStream delivers level 1 quote updates for a set of symbols as they happen.
+type QuoteStream struct {
+	streamer *streamer
+	quotes   chan Quote
+}
+
+// StreamQuotes subscribes to live quotes for the symbol ids. The stream
+// reconnects and resubscribes by itself until Close is called or ctx is
+// done; errors it recovers from are reported on Errors.
+func (c *Client) StreamQuotes(ctx context.Context, ids ...int) (*QuoteStream, error) {
+	qs := &QuoteStream{quotes: make(chan Quote, 64)}
+	params := map[string]string{
+		"ids":    joinIds(ids),
+		"stream": "true",
+		"mode":   "WebSocket",
+	}
+	qs.streamer = newStreamer(ctx, c, "v1/markets/quotes", params, qs.handle)
+	if err := qs.streamer.start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		<-qs.streamer.stopped
+		close(qs.quotes)
+	}()
+	return qs, nil
+}
+
+func (qs *QuoteStream) handle(message []byte) {
+	result := &QuotesResponse{}
+	if err := json.Unmarshal(message, result); err != nil {
+		qs.streamer.report(fmt.Errorf("unable to decode quote update: %w", err))
+		return
+	}
+	for _, q := range result.Quotes {
+		select {
+		case qs.quotes <- q:
+		case <-qs.streamer.done:
+			return
+		}
+	}
+}
+
+// Quotes returns the channel quote updates are delivered on. It is closed after Close.
+func (qs *QuoteStream) Quotes() <-chan Quote {
+	return qs.quotes
+}
+
+// Errors returns the channel recoverable stream errors, such as dropped
+// connections, are reported on. Errors are dropped if nobody reads them.
+func (qs *QuoteStream) Errors() <-chan error {
+	return qs.streamer.errs
+}
+
+// Close stops the stream.
+func (qs *QuoteStream) Close() error {
+	qs.streamer.close()
+	return nil
+}
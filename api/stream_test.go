@@ -0,0 +1,137 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+func newTestClient(t *testing.T, server *questradetest.Server) *api.Client {
+	t.Helper()
+	c := api.NewClient(nil)
+	c.LoginURL = server.LoginURL()
+	if _, err := c.Redeem(context.Background(), server.RefreshToken()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func nextQuote(t *testing.T, qs *api.QuoteStream) api.Quote {
+	t.Helper()
+	select {
+	case q, ok := <-qs.Quotes():
+		if !ok {
+			t.Fatal("quote stream closed")
+		}
+		return q
+	case <-time.After(5 * time.Second):
+		t.Fatal("no quote received")
+	}
+	return api.Quote{}
+}
+
+func TestStreamQuotesAuthFailure(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c := newTestClient(t, server)
+
+	// A rejected access token is refreshed and the stream connects with the new one.
+	server.FailStreamAuth()
+	qs, err := c.StreamQuotes(context.Background(), 9292)
+	if err != nil {
+		t.Fatalf("stream after one rejection: %v", err)
+	}
+	if q := nextQuote(t, qs); q.SymbolId != 9292 {
+		t.Errorf("got quote for %v, want 9292", q.SymbolId)
+	}
+	qs.Close()
+	if n := server.Count("POST oauth2/token"); n != 2 {
+		t.Errorf("redeemed %v times, want a redeem and a refresh", n)
+	}
+
+	// A token rejected again after the refresh is not retried forever.
+	server.FailStreamAuth()
+	server.FailStreamAuth()
+	if _, err = c.StreamQuotes(context.Background(), 9292); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("stream after two rejections: got %v, want ErrUnauthorized", err)
+	}
+
+	// So is a refresh token the login server no longer accepts.
+	c.Session.RefreshToken = "stale"
+	server.FailStreamAuth()
+	if _, err = c.StreamQuotes(context.Background(), 9292); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("stream with a stale refresh token: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestStreamQuotesReconnect(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	c := newTestClient(t, server)
+
+	qs, err := c.StreamQuotes(context.Background(), 9292, 40261)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qs.Close()
+	nextQuote(t, qs)
+	nextQuote(t, qs)
+
+	server.DropStreams()
+	select {
+	case err = <-qs.Errors():
+	case <-time.After(5 * time.Second):
+		t.Fatal("dropped connection was not reported")
+	}
+
+	// The new connection asks for a new port with the same symbols and
+	// gets their current quotes again.
+	seen := map[int]bool{}
+	seen[nextQuote(t, qs).SymbolId] = true
+	seen[nextQuote(t, qs).SymbolId] = true
+	if !seen[9292] || !seen[40261] {
+		t.Errorf("got quotes for %v after reconnecting, want 9292 and 40261", seen)
+	}
+	if n := server.Count("GET v1/markets/quotes"); n != 2 {
+		t.Errorf("asked for a stream port %v times, want 2", n)
+	}
+
+	server.PushQuotes(api.Quote{Symbol: "VFV.TO", SymbolId: 9292, LastTradePrice: 99.10})
+	if q := nextQuote(t, qs); q.SymbolId != 9292 || q.LastTradePrice != 99.10 {
+		t.Errorf("got %+v, want the pushed VFV.TO quote", q)
+	}
+}
+
+func TestStreamQuotesTokenExpiry(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	// Just over the 30 seconds before expiry at which the client refreshes,
+	// so the read deadline is reached after a second.
+	server.SetTokenLifetime(31 * time.Second)
+	c := newTestClient(t, server)
+
+	qs, err := c.StreamQuotes(context.Background(), 9292)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer qs.Close()
+	nextQuote(t, qs)
+	// The quote sent on the new connection shows the stream reconnected.
+	nextQuote(t, qs)
+
+	select {
+	case err = <-qs.Errors():
+		t.Errorf("reconnecting before the token expired reported %v", err)
+	default:
+	}
+	if n := server.Count("POST oauth2/token"); n != 2 {
+		t.Errorf("redeemed %v times, want a redeem and a refresh", n)
+	}
+	if n := server.Count("GET v1/markets/quotes"); n != 2 {
+		t.Errorf("asked for a stream port %v times, want 2", n)
+	}
+}
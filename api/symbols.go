@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type SecurityType string
+
+const (
+	SecurityTypeStock      SecurityType = "Stock"
+	SecurityTypeOption     SecurityType = "Option"
+	SecurityTypeBond       SecurityType = "Bond"
+	SecurityTypeRight      SecurityType = "Right"
+	SecurityTypeGold       SecurityType = "Gold"
+	SecurityTypeMutualFund SecurityType = "MutualFund"
+	SecurityTypeIndex      SecurityType = "Index"
+)
+
+type OptionType string
+
+const (
+	OptionTypeCall OptionType = "Call"
+	OptionTypePut  OptionType = "Put"
+)
+
+type MinTick struct {
+	Pivot   float64 `json:"pivot"`
+	MinTick float64 `json:"minTick"`
+}
+
+type UnderlyingMultiplierPair struct {
+	Multiplier         int    `json:"multiplier"`
+	UnderlyingSymbol   string `json:"underlyingSymbol"`
+	UnderlyingSymbolId int    `json:"underlyingSymbolId"`
+}
+
+type OptionDeliverables struct {
+	Underlyings []UnderlyingMultiplierPair `json:"underlyings"`
+	CashInLieu  float64                    `json:"cashInLieu"`
+}
+
+type Symbol struct {
+	Symbol                     string             `json:"symbol"`
+	SymbolId                   int                `json:"symbolId"`
+	Description                string             `json:"description"`
+	SecurityType               SecurityType       `json:"securityType"`
+	ListingExchange            string             `json:"listingExchange"`
+	Currency                   string             `json:"currency"`
+	PrevDayClosePrice          float64            `json:"prevDayClosePrice"`
+	HighPrice52                float64            `json:"highPrice52"`
+	LowPrice52                 float64            `json:"lowPrice52"`
+	AverageVol3Months          float64            `json:"averageVol3Months"`
+	AverageVol20Days           float64            `json:"averageVol20Days"`
+	OutstandingShares          float64            `json:"outstandingShares"`
+	Eps                        float64            `json:"eps"`
+	Pe                         float64            `json:"pe"`
+	Dividend                   float64            `json:"dividend"`
+	Yield                      float64            `json:"yield"`
+	ExDate                     Time               `json:"exDate"`
+	DividendDate               Time               `json:"dividendDate"`
+	MarketCap                  float64            `json:"marketCap"`
+	TradeUnit                  int                `json:"tradeUnit"`
+	OptionType                 OptionType         `json:"optionType"`
+	OptionDurationType         string             `json:"optionDurationType"`
+	OptionRoot                 string             `json:"optionRoot"`
+	OptionContractDeliverables OptionDeliverables `json:"optionContractDeliverables"`
+	OptionExerciseType         string             `json:"optionExerciseType"`
+	OptionExpiryDate           Time               `json:"optionExpiryDate"`
+	OptionStrikePrice          float64            `json:"optionStrikePrice"`
+	IsTradable                 bool               `json:"isTradable"`
+	IsQuotable                 bool               `json:"isQuotable"`
+	HasOptions                 bool               `json:"hasOptions"`
+	MinTicks                   []MinTick          `json:"minTicks"`
+	IndustrySector             string             `json:"industrySector"`
+	IndustryGroup              string             `json:"industryGroup"`
+	IndustrySubGroup           string             `json:"industrySubGroup"`
+}
+
+// MinTick returns the minimum price increment for orders at price.
+func (s *Symbol) MinTick(price float64) float64 {
+	tick := 0.0
+	for _, t := range s.MinTicks {
+		if price >= t.Pivot {
+			tick = t.MinTick
+		}
+	}
+	return tick
+}
+
+type SymbolsResponse struct {
+	Symbols []Symbol `json:"symbols"`
+}
+
+// EquitySymbol is the summary returned by SearchSymbols.
+type EquitySymbol struct {
+	Symbol          string       `json:"symbol"`
+	SymbolId        int          `json:"symbolId"`
+	Description     string       `json:"description"`
+	SecurityType    SecurityType `json:"securityType"`
+	ListingExchange string       `json:"listingExchange"`
+	IsTradable      bool         `json:"isTradable"`
+	IsQuotable      bool         `json:"isQuotable"`
+	Currency        string       `json:"currency"`
+}
+
+type SymbolSearchResponse struct {
+	Symbols []EquitySymbol `json:"symbols"`
+}
+
+// symbolCache keeps symbol metadata, which rarely changes, for the lifetime of a Client.
+type symbolCache struct {
+	mu     sync.Mutex
+	byId   map[int]Symbol
+	byName map[string]int
+}
+
+func (sc *symbolCache) get(id int) (Symbol, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	s, ok := sc.byId[id]
+	return s, ok
+}
+
+func (sc *symbolCache) lookup(name string) (Symbol, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	id, ok := sc.byName[strings.ToUpper(name)]
+	if !ok {
+		return Symbol{}, false
+	}
+	s, ok := sc.byId[id]
+	return s, ok
+}
+
+func (sc *symbolCache) put(symbols []Symbol) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.byId == nil {
+		sc.byId = map[int]Symbol{}
+		sc.byName = map[string]int{}
+	}
+	for _, s := range symbols {
+		sc.byId[s.SymbolId] = s
+		sc.byName[strings.ToUpper(s.Symbol)] = s.SymbolId
+	}
+}
+
+func (sc *symbolCache) clear() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.byId = nil
+	sc.byName = nil
+}
+
+// ClearSymbolCache forgets all symbols fetched so far.
+func (c *Client) ClearSymbolCache() {
+	c.symbols.clear()
+}
+
+// SymbolsByID returns the symbols with the given ids, in the same order.
+// Symbols already fetched by this client are served from its cache.
+func (c *Client) SymbolsByID(ctx context.Context, ids ...int) ([]Symbol, error) {
+	var missing []int
+	for _, id := range ids {
+		if _, ok := c.symbols.get(id); !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		result := &SymbolsResponse{}
+		if err := c.getJSON(ctx, "v1/symbols", map[string]string{"ids": joinIds(missing)}, result); err != nil {
+			return nil, err
+		}
+		c.symbols.put(result.Symbols)
+	}
+	symbols := make([]Symbol, 0, len(ids))
+	for _, id := range ids {
+		s, ok := c.symbols.get(id)
+		if !ok {
+			return nil, &ApiError{StatusCode: 404, Endpoint: "v1/symbols", Message: "unknown symbol id " + strconv.Itoa(id), sentinel: ErrNotFound}
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, nil
+}
+
+// SymbolsByName returns the symbols with the given tickers, e.g. VFV.TO, in the same order.
+// Symbols already fetched by this client are served from its cache.
+func (c *Client) SymbolsByName(ctx context.Context, names ...string) ([]Symbol, error) {
+	var missing []string
+	for _, name := range names {
+		if _, ok := c.symbols.lookup(name); !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		result := &SymbolsResponse{}
+		if err := c.getJSON(ctx, "v1/symbols", map[string]string{"names": strings.Join(missing, ",")}, result); err != nil {
+			return nil, err
+		}
+		c.symbols.put(result.Symbols)
+	}
+	symbols := make([]Symbol, 0, len(names))
+	for _, name := range names {
+		s, ok := c.symbols.lookup(name)
+		if !ok {
+			return nil, &ApiError{StatusCode: 404, Endpoint: "v1/symbols", Message: fmt.Sprintf("unknown symbol %v", name), sentinel: ErrNotFound}
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, nil
+}
+
+// SearchSymbols lists the symbols starting with prefix. offset skips that
+// many results, for paging through long lists.
+func (c *Client) SearchSymbols(ctx context.Context, prefix string, offset int) ([]EquitySymbol, error) {
+	result := &SymbolSearchResponse{}
+	params := map[string]string{"prefix": prefix}
+	if offset > 0 {
+		params["offset"] = strconv.Itoa(offset)
+	}
+	if err := c.getJSON(ctx, "v1/symbols/search", params, result); err != nil {
+		return nil, err
+	}
+	return result.Symbols, nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time is a timestamp in a Questrade response. Unlike time.Time it accepts
+// the empty string the server sends for dates that are not set.
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == `""` || s == "null" {
+		*t = Time{}
+		return nil
+	}
+	return t.Time.UnmarshalJSON(data)
+}
+
+// formatTime formats t the way Questrade expects it in query strings.
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// joinIds joins ids into the comma separated list used by the ids query parameter.
+func joinIds(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// expiryMargin is how long before ExpiresAt an access token is refreshed proactively.
+const expiryMargin = 30 * time.Second
+
+// TokenStore persists a Session between runs. A Client created with
+// NewClientFromStore saves the session every time the access token is
+// refreshed, so the rotated refresh token is never lost.
+type TokenStore interface {
+	Load() (*Session, error)
+	Save(session *Session) error
+}
+
+// FileTokenStore keeps the session as JSON in a local file.
+type FileTokenStore struct {
+	Path string
+}
+
+func (s *FileTokenStore) Load() (*Session, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err = json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *FileTokenStore) Save(session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.Path, data, 0600)
+}
+
+// Expired reports whether the access token expires within expiryMargin.
+// A session without ExpiresAt is never considered expired; the server's 401
+// is relied upon instead.
+func (s *Session) Expired() bool {
+	if s.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(expiryMargin).After(s.ExpiresAt)
+}
+
+// NewClientFromStore loads the session from store and returns a Client that
+// saves back to store whenever it refreshes the access token. The access
+// token is refreshed immediately if its expiry is unknown or near.
+func NewClientFromStore(ctx context.Context, store TokenStore) (*Client, error) {
+	c := NewClient(nil)
+	if err := c.UseStore(ctx, store); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UseStore is NewClientFromStore for a Client that is already set up, e.g.
+// with a different LoginURL.
+func (c *Client) UseStore(ctx context.Context, store TokenStore) error {
+	session, err := store.Load()
+	if err != nil {
+		return err
+	}
+	c.Session = session
+	c.TokenStore = store
+	if session.AccessToken == "" || session.ExpiresAt.IsZero() || session.Expired() {
+		if _, err = c.Redeem(ctx, session.RefreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh redeems the refresh token unless another request already replaced
+// staleToken while we were waiting for the lock.
+func (c *Client) refresh(ctx context.Context, staleToken string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Session.AccessToken != staleToken {
+		return nil
+	}
+	_, err := c.redeem(ctx, c.Session.RefreshToken)
+	return err
+}
+
+// accessToken returns a usable access token, refreshing it first if it is about to expire.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expired := c.Session.AccessToken, c.Session.Expired()
+	c.mu.Unlock()
+	if expired {
+		if err := c.refresh(ctx, token); err != nil {
+			return "", err
+		}
+		c.mu.Lock()
+		token = c.Session.AccessToken
+		c.mu.Unlock()
+	}
+	return token, nil
+}
@@ -0,0 +1,45 @@
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+func TestFileTokenStoreExpiry(t *testing.T) {
+	store := &api.FileTokenStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, want := range []time.Time{expiresAt, {}} {
+		if err := store.Save(&api.Session{AccessToken: "at", RefreshToken: "rt", ExpiresAt: want}); err != nil {
+			t.Fatal(err)
+		}
+		session, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !session.ExpiresAt.Equal(want) || session.Expired() {
+			t.Errorf("saved expiry %v, loaded %v, expired %v", want, session.ExpiresAt, session.Expired())
+		}
+	}
+
+	// A session saved without an expiry is refreshed when loaded.
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	data := `{"access_token":"at","api_server":"` + server.URL + `/","refresh_token":"` + server.RefreshToken() + `"}`
+	if err := ioutil.WriteFile(store.Path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := api.NewClient(nil)
+	c.LoginURL = server.LoginURL()
+	if err := c.UseStore(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+	if c.Session.AccessToken == "at" || c.Session.ExpiresAt.IsZero() {
+		t.Errorf("session without expiry was not refreshed: %+v", c.Session)
+	}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// This file implements the small subset of RFC 6455 the streaming endpoints
+// need: a client handshake, masked text frames out, and text, ping and close
+// frames in.
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage caps the size of a message read from the server.
+const maxWebSocketMessage = 16 << 20
+
+var errWebSocketClosed = errors.New("websocket closed by server")
+
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex
+}
+
+// dialWebSocket opens a WebSocket connection to rawurl, a ws:// or wss:// URL.
+func dialWebSocket(ctx context.Context, rawurl string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = dialer.DialContext(ctx, "tcp", u.Host)
+	case "wss":
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws, err := handshake(conn, u, timeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+func handshake(conn net.Conn, u *url.URL, timeout time.Duration) (*wsConn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method: "GET",
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket handshake with %v failed: %v", u.Host, resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("websocket handshake with %v failed: bad Sec-WebSocket-Accept", u.Host)
+	}
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+// writeFrame sends one masked frame, as clients must.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	header := []byte{0x80 | opcode, 0}
+	n := len(payload)
+	switch {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] = 127
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(n))
+		header = append(header, ext...)
+	}
+	header[1] |= 0x80
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	header = append(header, mask...)
+	masked := make([]byte, n)
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	_, err := ws.conn.Write(append(header, masked...))
+	return err
+}
+
+// WriteText sends data as a text message.
+func (ws *wsConn) WriteText(data []byte) error {
+	return ws.writeFrame(wsText, data)
+}
+
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(ws.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWebSocketMessage {
+		err = fmt.Errorf("websocket frame of %d bytes is too large", n)
+		return
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// ReadMessage returns the next text or binary message, answering pings and
+// reassembling fragmented messages along the way.
+func (ws *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err = ws.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			ws.writeFrame(wsClose, payload)
+			return nil, errWebSocketClosed
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				return nil, fmt.Errorf("websocket message is larger than %d bytes", maxWebSocketMessage)
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unexpected websocket opcode %d", opcode)
+		}
+	}
+}
+
+// SetReadDeadline bounds how long ReadMessage waits.
+func (ws *wsConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// Close sends a close frame and closes the connection.
+func (ws *wsConn) Close() error {
+	ws.writeFrame(wsClose, []byte{0x03, 0xe8})
+	return ws.conn.Close()
+}
@@ -0,0 +1,52 @@
+package api
+
+import "time"
+
+// window is a [Start, End) slice of a longer time range.
+type window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// splitRange cuts [start, end) into consecutive windows no longer than size.
+func splitRange(start, end time.Time, size time.Duration) []window {
+	var windows []window
+	for start.Before(end) {
+		next := start.Add(size)
+		if next.After(end) {
+			next = end
+		}
+		windows = append(windows, window{start, next})
+		start = next
+	}
+	return windows
+}
+
+// windowDedup drops the records a walk over consecutive windows gets twice:
+// the server includes the end of a window, so a record on the boundary is
+// listed by both windows next to it. Records are compared by key. A key may
+// legitimately repeat within one window, e.g. two equal fees on one day, so
+// it is kept as often as the window listing it most often does.
+type windowDedup struct {
+	kept    map[string]int
+	current map[string]int
+}
+
+// next starts a new window.
+func (d *windowDedup) next() {
+	if d.kept == nil {
+		d.kept = map[string]int{}
+	}
+	d.current = map[string]int{}
+}
+
+// keep counts a record with key in the current window and reports whether
+// it has not been returned before.
+func (d *windowDedup) keep(key string) bool {
+	d.current[key]++
+	if d.current[key] <= d.kept[key] {
+		return false
+	}
+	d.kept[key] = d.current[key]
+	return true
+}
@@ -0,0 +1,463 @@
+// Package cassette records the HTTP traffic of an api.Client to a fixture
+// file and replays it later, so a real-world payload captured once can be
+// used in regression tests without network access or credentials.
+//
+// Access tokens, refresh tokens and account numbers are replaced by
+// placeholders when a cassette is saved.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+// Placeholders written in place of secrets.
+const (
+	AccessToken  = "ACCESS_TOKEN"
+	RefreshToken = "REFRESH_TOKEN"
+)
+
+// accountPlaceholder returns the placeholder of the n-th account number seen.
+func accountPlaceholder(n int) string {
+	return fmt.Sprintf("9%07d", n)
+}
+
+// keptHeaders are the response headers saved with an interaction.
+var keptHeaders = []string{"Content-Type", "X-Request-Id", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"}
+
+// ignoredParams are left out when matching a request against the cassette:
+// time ranges depend on when the code runs and tokens are scrubbed.
+var ignoredParams = []string{"startTime", "endTime", "refresh_token"}
+
+type Mode int
+
+const (
+	Replay Mode = iota
+	Record
+)
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+// Response holds the body as JSON when it is valid JSON, so fixtures can be
+// read and edited by hand, and as Text otherwise.
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header,omitempty"`
+	JSON       json.RawMessage `json:"json,omitempty"`
+	Text       string          `json:"text,omitempty"`
+}
+
+// Cassette is an http.RoundTripper that either records the requests it
+// forwards to Transport or answers them from recorded interactions.
+type Cassette struct {
+	Path string
+	Mode Mode
+	// Transport reaches the real server while recording. It defaults to
+	// http.DefaultTransport.
+	Transport    http.RoundTripper
+	Interactions []Interaction
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// New returns a cassette that records to path once saved.
+func New(path string) *Cassette {
+	return &Cassette{Path: path, Mode: Record}
+}
+
+// Load reads the cassette at path for replay.
+func Load(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{Path: path, Mode: Replay}
+	if err = json.Unmarshal(data, &c.Interactions); err != nil {
+		return nil, fmt.Errorf("unable to read cassette %v: %w", path, err)
+	}
+	c.used = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+// Client returns an http.Client using the cassette, for api.Client.HTTPClient.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c.Mode == Record {
+		return c.record(req)
+	}
+	return c.replay(req)
+}
+
+// body splits data into the JSON and Text fields of an interaction.
+func body(data []byte) (json.RawMessage, string) {
+	if len(data) == 0 {
+		return nil, ""
+	}
+	if json.Valid(data) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err == nil {
+			return buf.Bytes(), ""
+		}
+	}
+	return nil, string(data)
+}
+
+func (c *Cassette) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String()},
+		Response: Response{StatusCode: resp.StatusCode, Header: http.Header{}},
+	}
+	i.Request.JSON, i.Request.Text = body(reqBody)
+	i.Response.JSON, i.Response.Text = body(respBody)
+	for _, h := range keptHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			i.Response.Header.Set(h, v)
+		}
+	}
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, i)
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// matchKey identifies a request by method, path and query, ignoring the
+// host, which differs between API servers, and ignoredParams.
+func matchKey(method string, u *url.URL) string {
+	q := u.Query()
+	for _, p := range ignoredParams {
+		q.Del(p)
+	}
+	return method + " " + u.Path + "?" + q.Encode()
+}
+
+// replay answers req with the first unused interaction matching it.
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	key := matchKey(req.Method, req.URL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n, i := range c.Interactions {
+		if c.used[n] {
+			continue
+		}
+		u, err := url.Parse(i.Request.URL)
+		if err != nil || matchKey(i.Request.Method, u) != key {
+			continue
+		}
+		c.used[n] = true
+		data := []byte(i.Response.JSON)
+		if i.Response.Text != "" {
+			data = []byte(i.Response.Text)
+		}
+		header := http.Header{}
+		for k, v := range i.Response.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %v has no interaction left for %v", c.Path, key)
+}
+
+// Save scrubs the recorded interactions and writes them to Path.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scrub()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Keep URLs readable: no \u0026 for every &.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c.Interactions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, buf.Bytes(), 0644)
+}
+
+// secrets collects the tokens and account numbers in the interactions,
+// mapped to their placeholders. Account numbers are taken from the accounts
+// response, from v1/accounts/{number}/... paths and from accountNumber
+// fields anywhere in a body.
+func (c *Cassette) secrets() map[string]string {
+	secrets := map[string]string{}
+	accounts := 0
+	account := func(number string) {
+		if _, ok := secrets[number]; !ok && number != "" {
+			accounts++
+			secrets[number] = accountPlaceholder(accounts)
+		}
+	}
+	for _, i := range c.Interactions {
+		if u, err := url.Parse(i.Request.URL); err == nil {
+			if t := u.Query().Get("refresh_token"); t != "" {
+				secrets[t] = RefreshToken
+			}
+			segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+			if len(segments) > 2 && segments[0] == "v1" && segments[1] == "accounts" {
+				account(segments[2])
+			}
+		}
+		for _, body := range []json.RawMessage{i.Request.JSON, i.Response.JSON} {
+			if body == nil {
+				continue
+			}
+			var v interface{}
+			if decode(body, &v) == nil {
+				walk(v, func(key string, value string) string {
+					if key == "accountNumber" {
+						account(value)
+					}
+					return value
+				})
+			}
+		}
+		if i.Response.JSON == nil {
+			continue
+		}
+		session := &api.Session{}
+		if json.Unmarshal(i.Response.JSON, session) == nil {
+			if session.AccessToken != "" {
+				secrets[session.AccessToken] = AccessToken
+			}
+			if session.RefreshToken != "" {
+				secrets[session.RefreshToken] = RefreshToken
+			}
+		}
+		result := &api.AccountsResponse{}
+		if json.Unmarshal(i.Response.JSON, result) == nil {
+			for _, a := range result.Accounts {
+				account(a.Number)
+			}
+		}
+	}
+	return secrets
+}
+
+// decode unmarshals data keeping numbers as written, so re-encoding a body
+// does not change any amount.
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// walk calls replace with every string value in v and the key it is stored
+// under, empty inside arrays, and stores what replace returns in its place.
+// Keys are visited in order so placeholders are numbered the same every time.
+func walk(v interface{}, replace func(key, value string) string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e := v[k]
+			if s, ok := e.(string); ok {
+				v[k] = replace(k, s)
+			} else {
+				walk(e, replace)
+			}
+		}
+	case []interface{}:
+		for n, e := range v {
+			if s, ok := e.(string); ok {
+				v[n] = replace("", s)
+			} else {
+				walk(e, replace)
+			}
+		}
+	}
+}
+
+// scrub replaces every secret by its placeholder where it makes up a whole
+// URL path segment or query value, and wherever it stands on its own in a
+// JSON string value or a text body, so ids or amounts that merely contain a
+// secret are left alone.
+func (c *Cassette) scrub() {
+	secrets := c.secrets()
+	replace := func(s string) string {
+		if placeholder, ok := secrets[s]; ok {
+			return placeholder
+		}
+		return s
+	}
+	scrubJSON := func(data json.RawMessage) json.RawMessage {
+		var v interface{}
+		if data == nil || decode(data, &v) != nil {
+			return data
+		}
+		walk(v, func(_, value string) string { return scrubText(value, secrets) })
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return data
+		}
+		return json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+	}
+	for n := range c.Interactions {
+		i := &c.Interactions[n]
+		i.Request.URL = scrubURL(i.Request.URL, replace)
+		i.Request.Text = scrubText(i.Request.Text, secrets)
+		i.Response.Text = scrubText(i.Response.Text, secrets)
+		i.Request.JSON = scrubJSON(i.Request.JSON)
+		i.Response.JSON = scrubJSON(i.Response.JSON)
+	}
+}
+
+func scrubURL(rawurl string, replace func(string) string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	segments := strings.Split(u.Path, "/")
+	for n := range segments {
+		segments[n] = replace(segments[n])
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		q := u.Query()
+		for _, values := range q {
+			for n := range values {
+				values[n] = replace(values[n])
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// scrubText replaces every occurrence of a secret in s that is not part of
+// a longer run of letters and digits, so "11111111-A" loses the account
+// number but "111111112" is kept.
+func scrubText(s string, secrets map[string]string) string {
+	// Longest first, so a secret containing another is replaced whole.
+	ordered := make([]string, 0, len(secrets))
+	for secret := range secrets {
+		ordered = append(ordered, secret)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if len(ordered[i]) != len(ordered[j]) {
+			return len(ordered[i]) > len(ordered[j])
+		}
+		return ordered[i] < ordered[j]
+	})
+	alnum := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for _, secret := range ordered {
+		if secret == "" {
+			continue
+		}
+		var b strings.Builder
+		rest := s
+		for {
+			n := strings.Index(rest, secret)
+			if n < 0 {
+				b.WriteString(rest)
+				break
+			}
+			end := n + len(secret)
+			before, _ := utf8.DecodeLastRuneInString(rest[:n])
+			after, _ := utf8.DecodeRuneInString(rest[end:])
+			if (n > 0 && alnum(before)) || (end < len(rest) && alnum(after)) {
+				b.WriteString(rest[:end])
+			} else {
+				b.WriteString(rest[:n])
+				b.WriteString(secrets[secret])
+			}
+			rest = rest[end:]
+		}
+		s = b.String()
+	}
+	return s
+}
+
+// Session returns a session for replaying the cassette: the scrubbed
+// access token, the recorded API server and an expiry far enough away that
+// the client never tries to refresh it.
+func (c *Cassette) Session() *api.Session {
+	session := &api.Session{
+		AccessToken:  AccessToken,
+		RefreshToken: RefreshToken,
+		ExpiresAt:    time.Now().AddDate(100, 0, 0),
+	}
+	for _, i := range c.Interactions {
+		s := &api.Session{}
+		if i.Response.JSON != nil && json.Unmarshal(i.Response.JSON, s) == nil && s.ApiServer != "" {
+			session.ApiServer = s.ApiServer
+			return session
+		}
+	}
+	// The session was not redeemed while recording; take the server of the
+	// first API request instead.
+	for _, i := range c.Interactions {
+		u, err := url.Parse(i.Request.URL)
+		if err == nil && strings.HasPrefix(u.Path, "/v1/") {
+			session.ApiServer = u.Scheme + "://" + u.Host + "/"
+			break
+		}
+	}
+	return session
+}
@@ -0,0 +1,72 @@
+package cassette
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScrub(t *testing.T) {
+	c := &Cassette{Interactions: []Interaction{
+		{
+			Request: Request{Method: "POST", URL: "https://login.questrade.com/oauth2/token?grant_type=refresh_token&refresh_token=rt1"},
+			Response: Response{StatusCode: 200, JSON: json.RawMessage(
+				`{"access_token":"at1","api_server":"https://api01.iq.questrade.com/","expires_in":1800,"refresh_token":"rt2","token_type":"Bearer"}`)},
+		},
+		{
+			Request:  Request{Method: "GET", URL: "https://api01.iq.questrade.com/v1/accounts"},
+			Response: Response{StatusCode: 200, JSON: json.RawMessage(`{"accounts":[{"number":"11111111","type":"TFSA"}],"userId":1}`)},
+		},
+		{
+			Request: Request{Method: "GET", URL: "https://api01.iq.questrade.com/v1/accounts/22222222/positions"},
+			Response: Response{StatusCode: 200, JSON: json.RawMessage(
+				`{"positions":[{"currentMarketValue":11111111.50,"symbol":"XYZ","symbolId":222222221}]}`)},
+		},
+		{
+			Request: Request{Method: "GET", URL: "https://api01.iq.questrade.com/v1/accounts/11111111/executions"},
+			Response: Response{StatusCode: 200, JSON: json.RawMessage(
+				`{"executions":[{"accountNumber":"33333333","id":1111111199,"notes":"see 11111111-A and 11111111, not 111111112"}]}`)},
+		},
+		{
+			Request:  Request{Method: "GET", URL: "https://api01.iq.questrade.com/v1/accounts/22222222/balances"},
+			Response: Response{StatusCode: 500, Text: "account 22222222 unavailable, ref 222222229"},
+		},
+	}}
+	c.scrub()
+
+	want := []struct {
+		url, json, text string
+	}{
+		{
+			url:  "https://login.questrade.com/oauth2/token?grant_type=refresh_token&refresh_token=REFRESH_TOKEN",
+			json: `{"access_token":"ACCESS_TOKEN","api_server":"https://api01.iq.questrade.com/","expires_in":1800,"refresh_token":"REFRESH_TOKEN","token_type":"Bearer"}`,
+		},
+		{
+			url:  "https://api01.iq.questrade.com/v1/accounts",
+			json: `{"accounts":[{"number":"90000001","type":"TFSA"}],"userId":1}`,
+		},
+		{
+			url:  "https://api01.iq.questrade.com/v1/accounts/90000002/positions",
+			json: `{"positions":[{"currentMarketValue":11111111.50,"symbol":"XYZ","symbolId":222222221}]}`,
+		},
+		{
+			url:  "https://api01.iq.questrade.com/v1/accounts/90000001/executions",
+			json: `{"executions":[{"accountNumber":"90000003","id":1111111199,"notes":"see 90000001-A and 90000001, not 111111112"}]}`,
+		},
+		{
+			url:  "https://api01.iq.questrade.com/v1/accounts/90000002/balances",
+			text: "account 90000002 unavailable, ref 222222229",
+		},
+	}
+	for n, w := range want {
+		i := c.Interactions[n]
+		if i.Request.URL != w.url {
+			t.Errorf("interaction %v: url %v, want %v", n, i.Request.URL, w.url)
+		}
+		if string(i.Response.JSON) != w.json {
+			t.Errorf("interaction %v: body %s, want %s", n, i.Response.JSON, w.json)
+		}
+		if i.Response.Text != w.text {
+			t.Errorf("interaction %v: text %q, want %q", n, i.Response.Text, w.text)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/dk1027/go-questrade-api/controlflow"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+const demoConfig = `storage: file
+login_url: %s
+sessions:
+  - name: demo
+    path: demo.json
+publisher:
+  type: log
+balances:
+  sessions:
+    - demo
+mappings:
+  VFV.TO: US
+  ZCN.TO: CANADA
+  ZAG.TO: BONDS
+  VIU.TO: WORLD
+  CASH: CASH
+ignored_accounts: []
+ignored_symbols: []
+target_allocation:
+  BONDS: 0.20
+  CASH: 0.02
+  CANADA: 0.26
+  US: 0.26
+  WORLD: 0.26
+`
+
+// Demo runs a balance check against a local stand-in for Questrade, in a
+// temporary directory, so no brokerage credentials are needed.
+func Demo() {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "questrade-demo")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Running demo against %s in %s\n", server.URL, dir)
+
+	store := &controlflow.IOTokenStore{IO: &controlflow.FileIO{}, Filename: "demo.json"}
+	if err = store.Save(server.Session()); err != nil {
+		log.Fatalln(err)
+	}
+	cf := controlflow.Parse([]byte(fmt.Sprintf(demoConfig, server.LoginURL())))
+	cf.Execute(context.Background())
+}
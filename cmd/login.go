@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/controlflow"
+)
+
+// loginTimeout bounds how long login waits for the user to grant access.
+const loginTimeout = 5 * time.Minute
+
+// Login authorizes the session called name in the config through the
+// browser and saves it to the config's storage.
+func Login(configFile, name string) {
+	bytes, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cf := controlflow.Parse(bytes)
+	ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+	defer cancel()
+	err = cf.Login(ctx, name, func(authorizeURL string) {
+		fmt.Printf("Open this URL to log in to Questrade:\n\n  %s\n\n", authorizeURL)
+		openBrowser(authorizeURL)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// openBrowser tries to open url in the default browser. Failing is fine,
+// the URL has been printed.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
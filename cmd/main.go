@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"log"
 	"os"
 
 	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/cassette"
 	"github.com/dk1027/go-questrade-api/controlflow"
 )
 
@@ -24,13 +26,19 @@ func main() {
 		Redeem(arg1, arg2)
 	case "check":
 		Check(arg1)
+	case "demo":
+		Demo()
+	case "record":
+		Record(arg1, arg2)
+	case "login":
+		Login(arg1, arg2)
 	default:
 		log.Printf("Undefined cmd %s\n", cmd)
 	}
 }
 
 func Redeem(refreshToken, output string) {
-	session, err := api.Redeem(refreshToken)
+	session, err := api.Redeem(context.Background(), refreshToken)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -45,5 +53,23 @@ func Check(configFile string) {
 		log.Fatalln(err)
 	}
 	cf := controlflow.Parse(bytes)
-	cf.Execute()
+	cf.Execute(context.Background())
+}
+
+// Record runs Check without storing or publishing its results and saves the
+// Questrade traffic, scrubbed, to cassetteFile.
+func Record(configFile, cassetteFile string) {
+	bytes, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cf := controlflow.Parse(bytes)
+	cf.DryRun = true
+	c := cassette.New(cassetteFile)
+	cf.HTTPClient = c.Client()
+	cf.Execute(context.Background())
+	if err = c.Save(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Recorded %d requests to %s\n", len(c.Interactions), cassetteFile)
 }
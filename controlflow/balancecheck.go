@@ -1,13 +1,22 @@
 package controlflow
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/dk1027/go-questrade-api/api"
 )
 
 type Checker struct {
+	Client *api.Client
+	// Session is used to build Client when only a session is given, as
+	// checkers were set up before they took a Client.
+	//
+	// Deprecated: set Client instead.
 	Session *api.Session
 }
 
@@ -17,42 +26,113 @@ func CHECK(e error, errMsg string) {
 	}
 }
 
-func NewChecker(refreshToken string) *Checker {
-	session, err := api.Redeem(refreshToken)
+func NewChecker(ctx context.Context, refreshToken string) *Checker {
+	client := api.NewClient(nil)
+	_, err := client.Redeem(ctx, refreshToken)
 	CHECK(err, "Error redeeming refresh token")
-	return &Checker{session}
+	return &Checker{Client: client}
 }
 
 type Portfolio []LineItem
 
 type LineItem struct {
-	Account string  `json:"Account"`
-	Symbol  string  `json:"Symbol"`
-	Amount  float64 `json:"Amount"`
+	Account     string  `json:"Account"`
+	Symbol      string  `json:"Symbol"`
+	Amount      float64 `json:"Amount"`
+	AccountType string  `json:"AccountType,omitempty"`
 }
 
 func (l LineItem) String() string {
 	return fmt.Sprintf("%s, %s, %v", l.Account, l.Symbol, l.Amount)
 }
 
-func (c *Checker) Get() Portfolio {
+// IncompleteError is returned by Get, together with the portfolio of the
+// accounts that could be read, when some accounts had to be skipped.
+type IncompleteError struct {
+	// Skipped maps the number of every skipped account to why it was skipped.
+	Skipped map[string]error
+}
+
+func (e *IncompleteError) Error() string {
+	var reasons []string
+	for _, account := range e.Accounts() {
+		reasons = append(reasons, fmt.Sprintf("%v: %v", account, e.Skipped[account]))
+	}
+	return "portfolio is incomplete, skipped " + strings.Join(reasons, "; ")
+}
+
+// Accounts returns the skipped account numbers in order.
+func (e *IncompleteError) Accounts() []string {
+	var accounts []string
+	for account := range e.Skipped {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// aborts tells whether err stops the whole check rather than one account:
+// the other accounts would fail the same way.
+func aborts(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, api.ErrUnauthorized) || errors.Is(err, api.ErrRateLimited)
+}
+
+// Get collects cash and positions of every account of the checker's session.
+// An account whose balances or positions can not be fetched is skipped and
+// the portfolio of the others is returned with an *IncompleteError. Errors
+// that would fail every account, such as a cancelled ctx, an unauthorized
+// session or a spent rate limit, end the check with no portfolio.
+func (c *Checker) Get(ctx context.Context) (Portfolio, error) {
+	if c.Client == nil {
+		c.Client = api.NewClient(c.Session)
+	}
 	var portfolio Portfolio
-	accounts, err := api.Accounts(c.Session)
-	CHECK(err, "Error getting accounts")
+	accounts, err := c.Client.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	skipped := map[string]error{}
 	for _, account := range accounts.Accounts {
-		balances, _ := api.Balances(c.Session, account.Number)
-
-		for _, balance := range balances.PerCurrencyBalances {
-			portfolio = append(portfolio, LineItem{account.Number, "CASH", balance.Cash})
+		if account.Status.Closed() {
+			log.Printf("Skipping closed account %v\n", account.Number)
+			continue
 		}
-
-		positions, _ := api.Positions(c.Session, account.Number)
-		for _, position := range positions.Positions {
-			portfolio = append(portfolio, LineItem{account.Number, position.Symbol, position.CurrentMarketValue})
+		items, err := c.account(ctx, account)
+		if err == nil {
+			portfolio = append(portfolio, items...)
+			continue
 		}
+		if aborts(ctx, err) {
+			return nil, err
+		}
+		log.Printf("Skipping account %v: %v\n", account.Number, err)
+		skipped[account.Number] = err
 	}
 	for _, line := range portfolio {
 		log.Println(line)
 	}
-	return portfolio
+	if len(skipped) > 0 {
+		return portfolio, &IncompleteError{Skipped: skipped}
+	}
+	return portfolio, nil
+}
+
+// account returns the cash and positions of one account.
+func (c *Checker) account(ctx context.Context, account api.Account) (Portfolio, error) {
+	balances, err := c.Client.Balances(ctx, account.Number)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := c.Client.Positions(ctx, account.Number)
+	if err != nil {
+		return nil, err
+	}
+	var items Portfolio
+	for _, balance := range balances.PerCurrencyBalances {
+		items = append(items, LineItem{account.Number, "CASH", balance.Cash, string(account.Type)})
+	}
+	for _, position := range positions.Positions {
+		items = append(items, LineItem{account.Number, position.Symbol, position.CurrentMarketValue, string(account.Type)})
+	}
+	return items, nil
 }
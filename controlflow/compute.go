@@ -48,6 +48,20 @@ func Filter(ignoredSymbols *[]string, ignoredAccounts *[]string, portfolio *Port
 	*portfolio = (*portfolio)[:i]
 }
 
+// FilterAccountTypes filters out rows in portfolio held in accounts of ignoredTypes in-place
+func FilterAccountTypes(ignoredTypes *[]string, portfolio *Portfolio) {
+	types := toSet(ignoredTypes)
+	i := 0
+	for _, p := range *portfolio {
+		if _, ignored := (*types)[p.AccountType]; ignored {
+			continue
+		}
+		(*portfolio)[i] = p
+		i++
+	}
+	*portfolio = (*portfolio)[:i]
+}
+
 func CalculatePercentBalance(table *Table, targetAllocation *map[string]float64) (*Table, *Table) {
 	var total float64
 	percent := Table{}
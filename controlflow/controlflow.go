@@ -1,10 +1,14 @@
 package controlflow
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/dk1027/go-questrade-api/api"
 
@@ -20,6 +24,7 @@ type S3Config struct {
 
 type ControlFlow struct {
 	Storage  *string `yaml:"storage" validate:"required"`
+	LoginURL *string `yaml:"login_url"`
 	Sessions *[]struct {
 		Name string `yaml:"name" validate:"required"`
 		Path string `yaml:"path" validate:"required"`
@@ -33,12 +38,30 @@ type ControlFlow struct {
 		TopicArn string `yaml:"topic_arn"`
 		Region   string `yaml:"region"`
 	} `yaml:"publisher"`
-	IgnoredAccounts  *[]string           `yaml:"ignored_accounts" validate:"required"`
-	IgnoredSymbols   *[]string           `yaml:"ignored_symbols" validate:"required"`
-	TargetAllocation *map[string]float64 `yaml:"target_allocation" validate:"required"`
-	s3Config         *S3Config
-	ioProvider       IOProvider
-	publisher        Publisher
+	MarketCalendar *struct {
+		Market   string   `yaml:"market" validate:"required"`
+		Holidays []string `yaml:"holidays"`
+	} `yaml:"market_calendar"`
+	OAuth *struct {
+		ConsumerKey  string `yaml:"consumer_key" validate:"required"`
+		RedirectURI  string `yaml:"redirect_uri" validate:"required"`
+		Implicit     bool   `yaml:"implicit"`
+		AuthorizeURL string `yaml:"authorize_url"`
+	} `yaml:"oauth"`
+	IgnoredAccounts     *[]string           `yaml:"ignored_accounts" validate:"required"`
+	IgnoredSymbols      *[]string           `yaml:"ignored_symbols" validate:"required"`
+	IgnoredAccountTypes *[]string           `yaml:"ignored_account_types"`
+	TargetAllocation    *map[string]float64 `yaml:"target_allocation" validate:"required"`
+	HTTPClient          *http.Client        `yaml:"-"`
+	// DryRun runs the check without writing the portfolio and aggregates to
+	// storage or publishing the report, which is only logged. Refreshed
+	// sessions are still saved, since the refresh token they replace no
+	// longer works.
+	DryRun bool `yaml:"-"`
+
+	s3Config   *S3Config
+	ioProvider IOProvider
+	publisher  Publisher
 }
 
 func (this *ControlFlow) String() string {
@@ -109,25 +132,53 @@ type SessionNode struct {
 	Fn   Func
 }
 
-func (this *ControlFlow) Execute() {
-	sessions := make(map[string]*api.Session)
-	// Load refresh token from file and then redeem refresh token
+// Execute pulls the portfolio of every session and publishes the allocation report.
+// ctx bounds every request made to the Questrade API.
+func (this *ControlFlow) Execute(ctx context.Context) {
+	clients := make(map[string]*api.Client)
+	// Load each session from storage; the client refreshes it and saves it back as needed
 	for _, sessionSection := range *this.Sessions {
 		log.Printf("Loading session %s\n", sessionSection.Path)
-		refreshToken := this.loadRefreshToken(sessionSection.Path)
-		sessions[sessionSection.Name] = this.redeem(refreshToken, sessionSection.Path)
+		client := api.NewClient(nil)
+		if this.LoginURL != nil {
+			client.LoginURL = *this.LoginURL
+		}
+		if this.HTTPClient != nil {
+			client.HTTPClient = this.HTTPClient
+		}
+		err := client.UseStore(ctx, &IOTokenStore{this.ioProvider, sessionSection.Path})
+		if err != nil {
+			log.Fatalf("Error loading session %s: %v", sessionSection.Name, err)
+		}
+		clients[sessionSection.Name] = client
+	}
+	if this.MarketCalendar != nil && !this.isTradingDay(ctx, clients[(*this.Sessions)[0].Name]) {
+		log.Printf("%s is closed today, skipping\n", this.MarketCalendar.Market)
+		return
 	}
 	// Pull data from accounts
 	portfolio := Portfolio{}
-	for _, session := range sessions {
+	var skipped []string
+	for _, client := range clients {
 		log.Printf("Checking portfolio balance..\n")
-		checker := &Checker{session}
-		portfolio = append(portfolio, checker.Get()...)
+		checker := &Checker{Client: client}
+		p, err := checker.Get(ctx)
+		var incomplete *IncompleteError
+		if errors.As(err, &incomplete) {
+			log.Printf("Warning: %v\n", err)
+			skipped = append(skipped, incomplete.Accounts()...)
+		} else if err != nil {
+			log.Fatalf("Error getting portfolio: %v", err)
+		}
+		portfolio = append(portfolio, p...)
 	}
-	Must(this.ioProvider.Write(portfolio, "portfolio.json"))
+	this.write(portfolio, "portfolio.json")
 	log.Print(portfolio)
 	// Filter out ignored symbols
 	Filter(this.IgnoredSymbols, this.IgnoredAccounts, &portfolio)
+	if this.IgnoredAccountTypes != nil {
+		FilterAccountTypes(this.IgnoredAccountTypes, &portfolio)
+	}
 	aggregates := Aggregate(this.Mappings, &portfolio)
 	log.Print(aggregates)
 	bytes, err := json.Marshal(aggregates)
@@ -135,7 +186,7 @@ func (this *ControlFlow) Execute() {
 		log.Fatalf("failed marshaling aggregation")
 	}
 
-	Must(this.ioProvider.Write(bytes, "aggregated.json"))
+	this.write(bytes, "aggregated.json")
 
 	diff, percent := CalculatePercentBalance(aggregates, this.TargetAllocation)
 
@@ -143,30 +194,42 @@ func (this *ControlFlow) Execute() {
 		Aggregtae:        aggregates,
 		Gap:              diff,
 		PercentPortfolio: percent,
+		SkippedAccounts:  skipped,
+	}
+	publisher := this.publisher
+	if this.DryRun {
+		publisher = &NullPublisher{}
 	}
-	Must(this.publisher.Publish(report))
+	Must(publisher.Publish(report))
 }
 
-// redeem a refresh token for a new session, and save the new session to a file
-func (this *ControlFlow) redeem(refreshToken, filename string) *api.Session {
-	session, err := api.Redeem(refreshToken)
-	if err != nil {
-		log.Fatalln(err)
+// write saves data to storage as filename, unless this is a dry run.
+func (this *ControlFlow) write(data interface{}, filename string) {
+	if this.DryRun {
+		log.Printf("Dry run, not writing %s\n", filename)
+		return
 	}
-	log.Println("Redeemed refresh token successfully")
-	j, _ := json.Marshal(session)
-	Must(this.ioProvider.Write(j, filename))
-	return session
+	Must(this.ioProvider.Write(data, filename))
 }
 
-// loadRefreshToken reads a previously saved session file and extracts the refresh token
-func (this *ControlFlow) loadRefreshToken(filename string) string {
-	session := &api.Session{}
-	err := this.ioProvider.Read(filename, session)
+// isTradingDay checks the configured market calendar to tell whether prices are live today
+func (this *ControlFlow) isTradingDay(ctx context.Context, client *api.Client) bool {
+	calendar, err := api.NewMarketCalendar(ctx, client)
+	if err != nil {
+		log.Fatalf("Error loading market calendar: %v", err)
+	}
+	for _, holiday := range this.MarketCalendar.Holidays {
+		date, err := time.Parse("2006-01-02", holiday)
+		if err != nil {
+			log.Fatalf("Invalid holiday %s: %v", holiday, err)
+		}
+		calendar.AddHoliday(this.MarketCalendar.Market, date)
+	}
+	open, err := calendar.IsTradingDay(this.MarketCalendar.Market, calendar.Now())
 	if err != nil {
 		log.Fatal(err)
 	}
-	return session.RefreshToken
+	return open
 }
 
 func Load(accessTokenFile string) string {
@@ -180,7 +243,7 @@ func Load(accessTokenFile string) string {
 }
 
 func Redeem(refreshToken, output string) *api.Session {
-	session, err := api.Redeem(refreshToken)
+	session, err := api.Redeem(context.Background(), refreshToken)
 	if err != nil {
 		log.Fatalln(err)
 	}
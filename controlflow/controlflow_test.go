@@ -0,0 +1,390 @@
+package controlflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+	"github.com/dk1027/go-questrade-api/cassette"
+	"github.com/dk1027/go-questrade-api/questradetest"
+)
+
+const testConfig = `storage: file
+login_url: %s
+sessions:
+  - name: test
+    path: session.json
+publisher:
+  type: log
+balances:
+  sessions:
+    - test
+mappings:
+  VFV.TO: US
+  ZCN.TO: CANADA
+  ZAG.TO: BONDS
+  VIU.TO: WORLD
+  CASH: CASH
+ignored_accounts: []
+ignored_symbols: []
+target_allocation:
+  BONDS: 0.20
+  CASH: 0.02
+  CANADA: 0.26
+  US: 0.26
+  WORLD: 0.26
+`
+
+// demoAggregate is what the positions and cash of questradetest.DemoFixtures add up to.
+var demoAggregate = Table{
+	"US":     120 * 98.52,
+	"CANADA": 300 * 27.10,
+	"BONDS":  250 * 13.74,
+	"WORLD":  200 * 32.45,
+	"CASH":   2 * 1500,
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the session Execute starts from.
+		setup func(t *testing.T, server *questradetest.Server) *api.Session
+		check func(t *testing.T, server *questradetest.Server)
+	}{
+		{
+			name: "fresh refresh token",
+			setup: func(t *testing.T, server *questradetest.Server) *api.Session {
+				return server.Session()
+			},
+			check: func(t *testing.T, server *questradetest.Server) {
+				if n := server.Count("POST oauth2/token"); n != 1 {
+					t.Errorf("redeemed %v times, want 1", n)
+				}
+			},
+		},
+		{
+			name: "expired access token",
+			setup: func(t *testing.T, server *questradetest.Server) *api.Session {
+				client := api.NewClient(nil)
+				client.LoginURL = server.LoginURL()
+				session, err := client.Redeem(context.Background(), server.RefreshToken())
+				if err != nil {
+					t.Fatal(err)
+				}
+				server.ExpireTokens()
+				return session
+			},
+			check: func(t *testing.T, server *questradetest.Server) {
+				// The stored session still looks valid, so the 401 triggers the refresh.
+				if n := server.Count("POST oauth2/token"); n != 2 {
+					t.Errorf("redeemed %v times, want a redeem and a refresh", n)
+				}
+				if n := server.Count("GET v1/accounts"); n != 2 {
+					t.Errorf("requested accounts %v times, want 2", n)
+				}
+			},
+		},
+		{
+			name: "server errors",
+			setup: func(t *testing.T, server *questradetest.Server) *api.Session {
+				server.Fail("v1/accounts", questradetest.Failure{Status: 503, Message: "Service unavailable"})
+				server.Fail("v1/accounts/11111111/balances", questradetest.Failure{Status: 502, Message: "Bad gateway"})
+				server.Fail("v1/accounts/11111111/balances", questradetest.Failure{Status: 500, Message: "Internal error"})
+				return server.Session()
+			},
+			check: func(t *testing.T, server *questradetest.Server) {
+				if n := server.Count("GET v1/accounts"); n != 2 {
+					t.Errorf("requested accounts %v times, want 2", n)
+				}
+				if n := server.Count("GET v1/accounts/11111111/balances"); n != 3 {
+					t.Errorf("requested balances %v times, want 3", n)
+				}
+			},
+		},
+		{
+			name: "rate limited",
+			setup: func(t *testing.T, server *questradetest.Server) *api.Session {
+				server.SetRateLimit(2, 2*time.Second)
+				// Spend the budget with another client, so the first request
+				// of Execute is answered with 429.
+				other := api.NewClient(nil)
+				other.LoginURL = server.LoginURL()
+				if _, err := other.Redeem(context.Background(), server.RefreshToken()); err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 2; i++ {
+					if _, err := other.Accounts(context.Background()); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return server.Session()
+			},
+			check: func(t *testing.T, server *questradetest.Server) {
+				if n := server.Count("GET v1/accounts"); n != 4 {
+					t.Errorf("requested accounts %v times, want 2 to spend the budget, a throttled one and its retry", n)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+
+			store := &IOTokenStore{IO: &FileIO{}, Filename: "session.json"}
+			if err := store.Save(tt.setup(t, server)); err != nil {
+				t.Fatal(err)
+			}
+			cf := Parse([]byte(fmt.Sprintf(testConfig, server.LoginURL())))
+			cf.Execute(context.Background())
+
+			aggregate := Table{}
+			if err := (&FileIO{}).Read("aggregated.json", &aggregate); err != nil {
+				t.Fatal(err)
+			}
+			for category, want := range demoAggregate {
+				if math.Abs(aggregate[category]-want) > 0.001 {
+					t.Errorf("%v: got %v, want %v", category, aggregate[category], want)
+				}
+			}
+			session, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.RefreshToken != server.RefreshToken() {
+				t.Error("the rotated refresh token was not saved")
+			}
+			tt.check(t, server)
+		})
+	}
+}
+
+// TestCheckerReplay runs the balance check on a cassette recorded from
+// questradetest.DemoFixtures and scrubbed, as a real capture would be.
+func TestCheckerReplay(t *testing.T) {
+	c, err := cassette.Load("testdata/checker.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := api.NewClient(c.Session())
+	client.HTTPClient = c.Client()
+	portfolio, err := (&Checker{Client: client}).Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Portfolio{
+		{"90000001", "CASH", 1500, "TFSA"},
+		{"90000001", "CASH", 0, "TFSA"},
+		{"90000001", "VFV.TO", 120 * 98.52, "TFSA"},
+		{"90000001", "ZCN.TO", 300 * 27.10, "TFSA"},
+		{"90000002", "CASH", 1500, "RRSP"},
+		{"90000002", "CASH", 0, "RRSP"},
+		{"90000002", "ZAG.TO", 250 * 13.74, "RRSP"},
+		{"90000002", "VIU.TO", 200 * 32.45, "RRSP"},
+	}
+	if len(portfolio) != len(want) {
+		t.Fatalf("got %v line items, want %v: %v", len(portfolio), len(want), portfolio)
+	}
+	for n, w := range want {
+		got := portfolio[n]
+		if got.Account != w.Account || got.Symbol != w.Symbol || got.AccountType != w.AccountType || math.Abs(got.Amount-w.Amount) > 0.001 {
+			t.Errorf("line %v: got %v, want %v", n, got, w)
+		}
+	}
+
+	mappings := map[string]string{"VFV.TO": "US", "ZCN.TO": "CANADA", "ZAG.TO": "BONDS", "VIU.TO": "WORLD", "CASH": "CASH"}
+	aggregate := *Aggregate(&mappings, &portfolio)
+	if len(aggregate) != len(demoAggregate) {
+		t.Errorf("got categories %v, want %v", aggregate, demoAggregate)
+	}
+	for category, want := range demoAggregate {
+		if math.Abs(aggregate[category]-want) > 0.001 {
+			t.Errorf("%v: got %v, want %v", category, aggregate[category], want)
+		}
+	}
+}
+
+func TestCheckerGetErrors(t *testing.T) {
+	newChecker := func(t *testing.T, server *questradetest.Server) *Checker {
+		client := api.NewClient(nil)
+		client.LoginURL = server.LoginURL()
+		if _, err := client.Redeem(context.Background(), server.RefreshToken()); err != nil {
+			t.Fatal(err)
+		}
+		return &Checker{Client: client}
+	}
+
+	t.Run("account skipped", func(t *testing.T) {
+		server := questradetest.NewServer(questradetest.DemoFixtures())
+		defer server.Close()
+		server.Fail("v1/accounts/11111111/positions", questradetest.Failure{Status: 404, Code: questradetest.CodeAccountNotFound, Message: "Account number not found"})
+		portfolio, err := newChecker(t, server).Get(context.Background())
+		var incomplete *IncompleteError
+		if !errors.As(err, &incomplete) {
+			t.Fatalf("got %v, want an IncompleteError", err)
+		}
+		if accounts := incomplete.Accounts(); len(accounts) != 1 || accounts[0] != "11111111" {
+			t.Errorf("skipped %v, want 11111111", accounts)
+		}
+		for _, line := range portfolio {
+			if line.Account != "22222222" {
+				t.Errorf("got line %v of a skipped account", line)
+			}
+		}
+		if len(portfolio) != 4 {
+			t.Errorf("got %v line items, want the 4 of account 22222222", len(portfolio))
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := questradetest.NewServer(questradetest.DemoFixtures())
+		defer server.Close()
+		unauthorized := questradetest.Failure{Status: 401, Code: questradetest.CodeInvalidToken, Message: "Access token is invalid"}
+		server.Fail("v1/accounts/11111111/balances", unauthorized)
+		server.Fail("v1/accounts/11111111/balances", unauthorized)
+		portfolio, err := newChecker(t, server).Get(context.Background())
+		if !errors.Is(err, api.ErrUnauthorized) || portfolio != nil {
+			t.Errorf("got %v and %v, want ErrUnauthorized and no portfolio", portfolio, err)
+		}
+		if n := server.Count("GET v1/accounts/22222222/balances"); n != 0 {
+			t.Errorf("went on to the next account after an unauthorized response")
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		server := questradetest.NewServer(questradetest.DemoFixtures())
+		defer server.Close()
+		checker := newChecker(t, server)
+		checker.Client.Options.FailOnRateLimit = true
+		server.Fail("v1/accounts/11111111/balances", questradetest.Failure{Status: 429, Code: questradetest.CodeRateLimited, Message: "Rate limit exceeded"})
+		if _, err := checker.Get(context.Background()); !errors.Is(err, api.ErrRateLimited) {
+			t.Errorf("got %v, want ErrRateLimited", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		server := questradetest.NewServer(questradetest.DemoFixtures())
+		defer server.Close()
+		checker := newChecker(t, server)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if portfolio, err := checker.Get(ctx); !errors.Is(err, context.Canceled) || portfolio != nil {
+			t.Errorf("got %v and %v, want context.Canceled and no portfolio", portfolio, err)
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	for _, implicit := range []bool{false, true} {
+		t.Run(fmt.Sprintf("implicit %v", implicit), func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			server := questradetest.NewServer(questradetest.DemoFixtures())
+			defer server.Close()
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			redirect := "http://" + l.Addr().String() + "/callback"
+			l.Close()
+			config := fmt.Sprintf(testConfig, server.LoginURL()) + fmt.Sprintf(`oauth:
+  consumer_key: consumer
+  redirect_uri: %s
+  authorize_url: %s
+  implicit: %v
+`, redirect, server.AuthorizeURL(), implicit)
+			cf := Parse([]byte(config))
+
+			done := make(chan struct{})
+			err = cf.Login(context.Background(), "test", func(authorizeURL string) {
+				go func() {
+					defer close(done)
+					// Stand in for the browser, including the page that
+					// forwards the fragment of the implicit flow.
+					browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+					resp, err := browser.Get(authorizeURL)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+					location, _ := url.Parse(resp.Header.Get("Location"))
+					if implicit {
+						location.RawQuery, location.Fragment = location.Fragment, ""
+					}
+					if resp, err = http.Get(location.String()); err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+				}()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-done
+			session, err := (&IOTokenStore{IO: &FileIO{}, Filename: "session.json"}).Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.RefreshToken != server.RefreshToken() || session.AccessToken == "" {
+				t.Errorf("saved session %+v, want the one the server granted", session)
+			}
+		})
+	}
+}
+
+func TestCheckerSession(t *testing.T) {
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	client := api.NewClient(nil)
+	client.LoginURL = server.LoginURL()
+	session, err := client.Redeem(context.Background(), server.RefreshToken())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A checker set up with only a session, the way it used to be, builds its own client.
+	portfolio, err := (&Checker{Session: session}).Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(portfolio) != 8 {
+		t.Errorf("got %v line items, want 8: %v", len(portfolio), portfolio)
+	}
+}
+
+func TestExecuteDryRun(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := questradetest.NewServer(questradetest.DemoFixtures())
+	defer server.Close()
+	store := &IOTokenStore{IO: &FileIO{}, Filename: "session.json"}
+	if err := store.Save(server.Session()); err != nil {
+		t.Fatal(err)
+	}
+	cf := Parse([]byte(fmt.Sprintf(testConfig, server.LoginURL())))
+	cf.DryRun = true
+	cf.Execute(context.Background())
+
+	for _, filename := range []string{"portfolio.json", "aggregated.json"} {
+		if _, err := os.Stat(filename); !os.IsNotExist(err) {
+			t.Errorf("dry run wrote %v", filename)
+		}
+	}
+	session, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.RefreshToken != server.RefreshToken() {
+		t.Error("the rotated refresh token was not saved")
+	}
+}
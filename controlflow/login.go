@@ -0,0 +1,46 @@
+package controlflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+// Login runs the OAuth flow of the oauth config section for the session
+// called name and saves the granted session where Execute loads it from.
+// show is called with the URL the user has to open in a browser.
+func (this *ControlFlow) Login(ctx context.Context, name string, show func(authorizeURL string)) error {
+	if this.OAuth == nil {
+		return fmt.Errorf("login needs an oauth section in the config")
+	}
+	path := ""
+	for _, sessionSection := range *this.Sessions {
+		if sessionSection.Name == name {
+			path = sessionSection.Path
+		}
+	}
+	if path == "" {
+		return fmt.Errorf("no session named %s in the config", name)
+	}
+	client := api.NewClient(nil)
+	if this.LoginURL != nil {
+		client.LoginURL = *this.LoginURL
+	}
+	if this.HTTPClient != nil {
+		client.HTTPClient = this.HTTPClient
+	}
+	client.TokenStore = &IOTokenStore{this.ioProvider, path}
+	cfg := api.OAuthConfig{
+		ClientID:     this.OAuth.ConsumerKey,
+		RedirectURI:  this.OAuth.RedirectURI,
+		AuthorizeURL: this.OAuth.AuthorizeURL,
+		Implicit:     this.OAuth.Implicit,
+	}
+	if _, err := client.Authorize(ctx, cfg, show); err != nil {
+		return err
+	}
+	log.Printf("Saved session %s to %s\n", name, path)
+	return nil
+}
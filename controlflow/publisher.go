@@ -1,8 +1,10 @@
 package controlflow
 
 import (
+	"fmt"
 	"log"
 	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 
@@ -14,6 +16,23 @@ type Report struct {
 	Aggregtae        *Table
 	Gap              *Table
 	PercentPortfolio *Table
+	// SkippedAccounts lists the accounts left out because they could not
+	// be read; the report is partial if there are any.
+	SkippedAccounts []string
+}
+
+// Partial tells whether some accounts are missing from the report.
+func (r *Report) Partial() bool {
+	return len(r.SkippedAccounts) > 0
+}
+
+// text renders the report as a table, headed by a warning if it is partial.
+func (r *Report) text(headers []string) string {
+	s := ToText(headers, []Table{*r.Aggregtae, *r.Gap, *r.PercentPortfolio})
+	if r.Partial() {
+		s = fmt.Sprintf("PARTIAL REPORT, accounts %v could not be read\n%v", strings.Join(r.SkippedAccounts, ", "), s)
+	}
+	return s
 }
 
 type Publisher interface {
@@ -41,7 +60,7 @@ func (p *SNSPublisher) Publish(report *Report) error {
 		headers = append(headers, k)
 	}
 	sort.Strings(headers)
-	s := ToText(headers, []Table{*report.Aggregtae, *report.Gap, *report.PercentPortfolio})
+	s := report.text(headers)
 	log.Println(s)
 	input := &sns.PublishInput{}
 	input.SetTopicArn(p.topicArn)
@@ -62,7 +81,7 @@ func (n *NullPublisher) Publish(report *Report) error {
 	for k := range *report.Aggregtae {
 		headers = append(headers, k)
 	}
-	s := ToText(headers, []Table{*report.Aggregtae, *report.Gap, *report.PercentPortfolio})
+	s := report.text(headers)
 	log.Println(s)
 	return nil
 }
@@ -0,0 +1,30 @@
+package controlflow
+
+import (
+	"encoding/json"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+// IOTokenStore saves sessions through an IOProvider so that refreshed tokens
+// end up wherever the config's storage option points.
+type IOTokenStore struct {
+	IO       IOProvider
+	Filename string
+}
+
+func (s *IOTokenStore) Load() (*api.Session, error) {
+	session := &api.Session{}
+	if err := s.IO.Read(s.Filename, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *IOTokenStore) Save(session *api.Session) error {
+	j, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.IO.Write(j, s.Filename)
+}
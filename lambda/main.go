@@ -24,14 +24,14 @@ type MyEvent struct {
 	ConfigPath string `json:"config_path"`
 }
 
-func HandleRequest(_ context.Context, _ MyEvent) (string, error) {
+func HandleRequest(ctx context.Context, _ MyEvent) (string, error) {
 	log.Println("starting lambda")
 	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String(region)}))
 	downloader := s3manager.NewDownloader(sess)
 	buff := &aws.WriteAtBuffer{}
 	key := fmt.Sprintf("%s/%s", s3_prefix, "config.yaml")
 	log.Printf("Downloading %s", key)
-	_, err := downloader.Download(buff,
+	_, err := downloader.DownloadWithContext(ctx, buff,
 		&s3.GetObjectInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
@@ -41,7 +41,7 @@ func HandleRequest(_ context.Context, _ MyEvent) (string, error) {
 	}
 
 	cf := controlflow.Parse(buff.Bytes())
-	cf.Execute()
+	cf.Execute(ctx)
 	return "", nil
 }
 
@@ -0,0 +1,116 @@
+package questradetest
+
+import (
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+type holding struct {
+	account  string
+	symbol   string
+	id       int
+	quantity float64
+	price    float64
+}
+
+var demoSymbols = []api.Symbol{
+	{Symbol: "VFV.TO", SymbolId: 9292, Description: "VANGUARD S&P 500 INDEX ETF", SecurityType: api.SecurityTypeStock, ListingExchange: "TSX", Currency: "CAD"},
+	{Symbol: "ZAG.TO", SymbolId: 40257, Description: "BMO AGGREGATE BOND INDEX ETF", SecurityType: api.SecurityTypeStock, ListingExchange: "TSX", Currency: "CAD"},
+	{Symbol: "ZCN.TO", SymbolId: 40261, Description: "BMO S&P/TSX CAPPED COMPOSITE INDEX ETF", SecurityType: api.SecurityTypeStock, ListingExchange: "TSX", Currency: "CAD"},
+	{Symbol: "VIU.TO", SymbolId: 9338, Description: "VANGUARD FTSE DEV ALL CAP EX NORTH AMERICA INDEX ETF", SecurityType: api.SecurityTypeStock, ListingExchange: "TSX", Currency: "CAD"},
+}
+
+var demoHoldings = []holding{
+	{"11111111", "VFV.TO", 9292, 120, 98.52},
+	{"11111111", "ZCN.TO", 40261, 300, 27.10},
+	{"22222222", "ZAG.TO", 40257, 250, 13.74},
+	{"22222222", "VIU.TO", 9338, 200, 32.45},
+}
+
+// DemoFixtures returns a user with a TFSA and an RRSP holding a few index
+// ETFs, with matching symbols, quotes, a month of daily candles and the
+// purchases as activities.
+func DemoFixtures() *Fixtures {
+	f := &Fixtures{
+		UserId: 1000001,
+		Accounts: []api.Account{
+			{Type: api.AccountTypeTFSA, Number: "11111111", Status: api.AccountStatusActive, IsPrimary: true, IsBilling: true, ClientAccountType: api.ClientAccountTypeIndividual},
+			{Type: api.AccountTypeRRSP, Number: "22222222", Status: api.AccountStatusActive, ClientAccountType: api.ClientAccountTypeIndividual},
+		},
+		Positions:  map[string][]api.Position{},
+		Balances:   map[string]api.BalancesResponse{},
+		Activities: map[string][]api.Activity{},
+		Orders:     map[string][]api.Order{},
+		Executions: map[string][]api.Execution{},
+		Symbols:    demoSymbols,
+		Candles:    map[int][]api.Candle{},
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	bought := today.AddDate(0, -1, 0)
+	marketValue := map[string]float64{}
+	for _, h := range demoHoldings {
+		value := h.quantity * h.price
+		cost := h.quantity * h.price * 0.95
+		marketValue[h.account] += value
+		f.Positions[h.account] = append(f.Positions[h.account], api.Position{
+			Symbol:             h.symbol,
+			SymbolId:           h.id,
+			OpenQuantity:       h.quantity,
+			CurrentMarketValue: value,
+			CurrentPrice:       h.price,
+			AverageEntryPrice:  h.price * 0.95,
+			TotalCost:          cost,
+			OpenPnl:            value - cost,
+		})
+		f.Activities[h.account] = append(f.Activities[h.account], api.Activity{
+			TradeDate:       api.Time{Time: bought},
+			TransactionDate: api.Time{Time: bought},
+			SettlementDate:  api.Time{Time: bought.AddDate(0, 0, 2)},
+			Action:          "Buy",
+			Symbol:          h.symbol,
+			SymbolId:        h.id,
+			Currency:        "CAD",
+			Quantity:        h.quantity,
+			Price:           h.price * 0.95,
+			GrossAmount:     -cost,
+			NetAmount:       -cost,
+			Type:            api.ActivityTypeTrades,
+		})
+		f.Quotes = append(f.Quotes, api.Quote{
+			Symbol:         h.symbol,
+			SymbolId:       h.id,
+			BidPrice:       h.price - 0.01,
+			AskPrice:       h.price + 0.01,
+			LastTradePrice: h.price,
+			LastTradeTime:  api.Time{Time: now},
+		})
+		for d := bought; d.Before(today); d = d.AddDate(0, 0, 1) {
+			// Drift linearly from the entry price to today's price.
+			p := h.price * (0.95 + 0.05*float64(d.Sub(bought))/float64(today.Sub(bought)))
+			f.Candles[h.id] = append(f.Candles[h.id], api.Candle{
+				Start: api.Time{Time: d},
+				End:   api.Time{Time: d.AddDate(0, 0, 1)},
+				Open:  p,
+				Close: p,
+				Low:   p * 0.99,
+				High:  p * 1.01,
+			})
+		}
+	}
+	for _, a := range f.Accounts {
+		cash := 1500.0
+		balances := []api.Balance{
+			{Currency: "CAD", Cash: cash, MarketValue: marketValue[a.Number], TotalEquity: cash + marketValue[a.Number], BuyingPower: cash},
+			{Currency: "USD"},
+		}
+		f.Balances[a.Number] = api.BalancesResponse{
+			PerCurrencyBalances:    balances,
+			CombinedBalances:       balances,
+			SodPerCurrencyBalances: balances,
+			SodCombinedBalances:    balances,
+		}
+	}
+	return f
+}
@@ -0,0 +1,226 @@
+package questradetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+// orders serves v1/accounts/{number}/orders and the requests below it:
+// listing and fetching orders, previewing their impact, and placing,
+// replacing and cancelling them. Orders are accepted but never filled; their
+// changes are sent to the notification streams.
+func (s *Server) orders(w http.ResponseWriter, r *http.Request, number string, rest []string) {
+	if !s.hasAccount(w, number) {
+		return
+	}
+	switch {
+	case len(rest) == 0 && r.Method == "GET":
+		s.listOrders(w, r, number)
+	case len(rest) == 0 && r.Method == "POST":
+		s.placeOrder(w, r, number)
+	case len(rest) == 1 && rest[0] == "impact" && r.Method == "POST":
+		s.orderImpact(w, r)
+	case len(rest) == 1:
+		id, err := strconv.Atoi(rest[0])
+		if err != nil {
+			writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: "invalid order id"})
+			return
+		}
+		s.order(w, r, number, id)
+	default:
+		writeError(w, Failure{Status: 404, Code: CodeInvalidEndpoint, Message: "Invalid endpoint"})
+	}
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request, number string) {
+	result := api.OrdersResponse{Orders: []api.Order{}}
+	if v := r.URL.Query().Get("ids"); v != "" {
+		wanted, err := ids(v)
+		if err != nil {
+			writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+			return
+		}
+		for _, id := range wanted {
+			if o := s.findOrder(number, id); o != nil {
+				result.Orders = append(result.Orders, *o)
+			}
+		}
+		writeJSON(w, 200, result)
+		return
+	}
+	start, end, err := timeRange(r)
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return
+	}
+	filter := api.OrderStateFilter(r.URL.Query().Get("stateFilter"))
+	for _, o := range s.Fixtures.Orders[number] {
+		if !inRange(o.CreationTime.Time, start, end) ||
+			filter == api.OrderStateFilterOpen && o.State.Closed() ||
+			filter == api.OrderStateFilterClosed && !o.State.Closed() {
+			continue
+		}
+		result.Orders = append(result.Orders, o)
+	}
+	writeJSON(w, 200, result)
+}
+
+// order serves a single order: GET fetches it, POST replaces it and DELETE
+// cancels it.
+func (s *Server) order(w http.ResponseWriter, r *http.Request, number string, id int) {
+	o := s.findOrder(number, id)
+	if r.Method == "GET" {
+		result := api.OrdersResponse{Orders: []api.Order{}}
+		if o != nil {
+			result.Orders = append(result.Orders, *o)
+		}
+		writeJSON(w, 200, result)
+		return
+	}
+	if o == nil {
+		writeError(w, Failure{Status: 404, Code: CodeInvalidArgument, Message: "Order not found"})
+		return
+	}
+	if o.State.Closed() || o.State == api.OrderStateCancelPending || o.State == api.OrderStateReplacePending {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: "Order can not be changed in state " + string(o.State)})
+		return
+	}
+	switch r.Method {
+	case "DELETE":
+		o.State = api.OrderStateCanceled
+		o.CanceledQuantity, o.OpenQuantity = o.OpenQuantity, 0
+		o.UpdateTime = api.Time{Time: time.Now()}
+		s.notify(number, []api.Order{*o}, nil)
+		writeJSON(w, 200, api.CancelResult{OrderId: id})
+	case "POST":
+		req, ok := orderRequest(w, r)
+		if !ok {
+			return
+		}
+		o.State = api.OrderStateReplaced
+		o.UpdateTime = api.Time{Time: time.Now()}
+		replaced := *o
+		placed := s.addOrder(number, req, o.ChainId)
+		s.notify(number, []api.Order{replaced, placed}, nil)
+		writeJSON(w, 200, api.OrderResult{OrderId: placed.Id, Orders: []api.Order{placed}})
+	default:
+		writeError(w, Failure{Status: 404, Code: CodeInvalidEndpoint, Message: "Invalid endpoint"})
+	}
+}
+
+func (s *Server) placeOrder(w http.ResponseWriter, r *http.Request, number string) {
+	req, ok := orderRequest(w, r)
+	if !ok {
+		return
+	}
+	placed := s.addOrder(number, req, 0)
+	s.notify(number, []api.Order{placed}, nil)
+	writeJSON(w, 200, api.OrderResult{OrderId: placed.Id, Orders: []api.Order{placed}})
+}
+
+// orderImpact estimates the order at its limit price, or at the last trade
+// price of the symbol if it has none, without commissions.
+func (s *Server) orderImpact(w http.ResponseWriter, r *http.Request) {
+	req, ok := orderRequest(w, r)
+	if !ok {
+		return
+	}
+	price := req.LimitPrice
+	for _, q := range s.Fixtures.Quotes {
+		if price == 0 && q.SymbolId == req.SymbolId {
+			price = q.LastTradePrice
+		}
+	}
+	effect := req.Quantity * price
+	if req.Action == api.OrderSideBuy || req.Action == api.OrderSideCover || req.Action == api.OrderSideBTO || req.Action == api.OrderSideBTC {
+		effect = -effect
+	}
+	writeJSON(w, 200, api.OrderImpact{
+		BuyingPowerEffect:     effect,
+		Side:                  req.Action,
+		TradeValueCalculation: strconv.FormatFloat(req.Quantity, 'f', -1, 64) + " x " + strconv.FormatFloat(price, 'f', -1, 64),
+		Price:                 price,
+	})
+}
+
+// orderRequest decodes the body of r, answering 400 if it is not a valid order.
+func orderRequest(w http.ResponseWriter, r *http.Request) (*api.OrderRequest, bool) {
+	req := &api.OrderRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return nil, false
+	}
+	return req, true
+}
+
+// findOrder returns the order id of account number, or nil.
+func (s *Server) findOrder(number string, id int) *api.Order {
+	orders := s.Fixtures.Orders[number]
+	for i := range orders {
+		if orders[i].Id == id {
+			return &orders[i]
+		}
+	}
+	return nil
+}
+
+// addOrder accepts req as a new order. It starts a new chain unless chainId
+// names the chain of the order it replaces.
+func (s *Server) addOrder(number string, req *api.OrderRequest, chainId int) api.Order {
+	id := 1
+	for _, orders := range s.Fixtures.Orders {
+		for _, o := range orders {
+			if o.Id >= id {
+				id = o.Id + 1
+			}
+		}
+	}
+	if chainId == 0 {
+		chainId = id
+	}
+	symbol := ""
+	for _, sym := range s.Fixtures.Symbols {
+		if sym.SymbolId == req.SymbolId {
+			symbol = sym.Symbol
+		}
+	}
+	now := api.Time{Time: time.Now()}
+	o := api.Order{
+		Id:              id,
+		Symbol:          symbol,
+		SymbolId:        req.SymbolId,
+		TotalQuantity:   req.Quantity,
+		OpenQuantity:    req.Quantity,
+		Side:            req.Action,
+		OrderType:       req.OrderType,
+		LimitPrice:      req.LimitPrice,
+		StopPrice:       req.StopPrice,
+		IsAllOrNone:     req.IsAllOrNone,
+		IsAnonymous:     req.IsAnonymous,
+		IcebergQuantity: req.IcebergQuantity,
+		TimeInForce:     req.TimeInForce,
+		State:           api.OrderStateAccepted,
+		ChainId:         chainId,
+		CreationTime:    now,
+		UpdateTime:      now,
+		PrimaryRoute:    req.PrimaryRoute,
+		SecondaryRoute:  req.SecondaryRoute,
+		UserId:          s.Fixtures.UserId,
+	}
+	if req.GtdDate != nil {
+		o.GtdDate = api.Time{Time: *req.GtdDate}
+	}
+	if s.Fixtures.Orders == nil {
+		s.Fixtures.Orders = map[string][]api.Order{}
+	}
+	s.Fixtures.Orders[number] = append(s.Fixtures.Orders[number], o)
+	return o
+}
@@ -0,0 +1,634 @@
+// Package questradetest runs an in-process stand-in for the Questrade API.
+// It serves scripted Fixtures through the OAuth token endpoint and the
+// account, order, symbol and market data endpoints, including WebSocket quote
+// and notification streams, so clients and control flows can be exercised without a
+// brokerage account.
+package questradetest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+// maxCandles is the most candles returned for one request.
+const maxCandles = 2000
+
+// Error codes sent in error bodies, as the real API does.
+const (
+	CodeInvalidEndpoint = 1001
+	CodeInvalidArgument = 1002
+	CodeAccountNotFound = 1003
+	CodeRateLimited     = 1006
+	CodeInvalidToken    = 1017
+)
+
+// Fixtures is the brokerage data the Server answers with, keyed by account
+// number or symbol id where the endpoint is per account or per symbol.
+// Candles are served whatever interval is asked for, at most 2000 per
+// request like the real API.
+type Fixtures struct {
+	UserId     int
+	Accounts   []api.Account
+	Positions  map[string][]api.Position
+	Balances   map[string]api.BalancesResponse
+	Activities map[string][]api.Activity
+	// Orders are listed, placed, replaced and cancelled through the order
+	// endpoints. New orders get ids above the highest one present.
+	Orders     map[string][]api.Order
+	Executions map[string][]api.Execution
+	Symbols    []api.Symbol
+	Quotes     []api.Quote
+	Candles    map[int][]api.Candle
+}
+
+// Failure is a scripted error response.
+type Failure struct {
+	Status  int
+	Code    int
+	Message string
+	Header  http.Header
+	// Drop closes the connection instead of answering.
+	Drop bool
+	// After serves the request before failing, so its effects, such as a
+	// cancelled order, take place although the client never learns of them.
+	After bool
+}
+
+// Server is a running stand-in API server. Set its fields before the first
+// request; while it runs, change them through SetTokenLifetime, SetRateLimit
+// and UpdateFixtures, which synchronize with the requests being served.
+type Server struct {
+	// URL is the base URL of the server, without a trailing slash.
+	URL      string
+	Fixtures *Fixtures
+	// TokenLifetime is how long issued access tokens are valid.
+	TokenLifetime time.Duration
+	// RateLimit is how many requests each rate-limit category allows per
+	// RateWindow. Zero means unlimited.
+	RateLimit  int
+	RateWindow time.Duration
+
+	srv          *httptest.Server
+	mu           sync.Mutex
+	refreshToken string
+	codes        map[string]bool
+	accessTokens map[string]time.Time
+	budgets      map[api.RateCategory]*budget
+	failures     map[string][]Failure
+	requests     []string
+
+	listeners          []net.Listener
+	streams            map[*streamConn]bool
+	streamAuthFailures int
+}
+
+type budget struct {
+	remaining int
+	reset     time.Time
+}
+
+// NewServer starts a server answering with fixtures. Close it when done.
+func NewServer(fixtures *Fixtures) *Server {
+	s := &Server{
+		Fixtures:      fixtures,
+		TokenLifetime: 30 * time.Minute,
+		RateWindow:    time.Second,
+		refreshToken:  newToken(),
+		codes:         map[string]bool{},
+		accessTokens:  map[string]time.Time{},
+		budgets:       map[api.RateCategory]*budget{},
+		failures:      map[string][]Failure{},
+		streams:       map[*streamConn]bool{},
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server and its streams down.
+func (s *Server) Close() {
+	s.srv.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	for sc := range s.streams {
+		sc.conn.Close()
+	}
+}
+
+// LoginURL returns the URL of the OAuth token endpoint, for api.Client.LoginURL.
+func (s *Server) LoginURL() string {
+	return s.URL + "/oauth2/token"
+}
+
+// AuthorizeURL returns the URL of the OAuth authorize page, for
+// api.OAuthConfig.AuthorizeURL. The page grants access right away.
+func (s *Server) AuthorizeURL() string {
+	return s.URL + "/oauth2/authorize"
+}
+
+// RefreshToken returns the refresh token the server accepts next. Every
+// redeem replaces it, like the real login server does.
+func (s *Server) RefreshToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshToken
+}
+
+// Session returns a session holding only the current refresh token, the way
+// a freshly generated token from the Questrade app hub starts out.
+func (s *Server) Session() *api.Session {
+	return &api.Session{RefreshToken: s.RefreshToken()}
+}
+
+// ExpireTokens invalidates every access token issued so far. The next
+// request with one of them is answered with 401.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = map[string]time.Time{}
+}
+
+// Fail makes the next request matching request fail with f. Like for Count,
+// request is a path such as "v1/accounts" or a method and path such as
+// "DELETE v1/accounts/11111111/orders/1". Failures queued for the same
+// request are returned in order.
+func (s *Server) Fail(request string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[request] = append(s.failures[request], f)
+}
+
+// SetTokenLifetime changes how long access tokens issued from now on are valid.
+func (s *Server) SetTokenLifetime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TokenLifetime = d
+}
+
+// SetRateLimit changes the rate limit and starts new windows for every category.
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RateLimit = limit
+	s.RateWindow = window
+	s.budgets = map[api.RateCategory]*budget{}
+}
+
+// UpdateFixtures calls update with the fixtures while no request is served.
+func (s *Server) UpdateFixtures(update func(f *Fixtures)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s.Fixtures)
+}
+
+// Count returns how many of the requests served so far match request,
+// either a path such as "v1/accounts" or a method and path such as
+// "POST oauth2/token".
+func (s *Server) Count(request string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r == request || r[strings.Index(r, " ")+1:] == request {
+			n++
+		}
+	}
+	return n
+}
+
+// Requests returns the requests served so far as "METHOD path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// fail answers with f, or drops the connection if f says so.
+func fail(w http.ResponseWriter, f Failure) {
+	if f.Drop {
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	writeError(w, f)
+}
+
+func writeError(w http.ResponseWriter, f Failure) {
+	for k, v := range f.Header {
+		w.Header()[k] = v
+	}
+	writeJSON(w, f.Status, map[string]interface{}{"code": f.Code, "message": f.Message})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+path)
+	var failure *Failure
+	for _, key := range []string{r.Method + " " + path, path} {
+		if queued := s.failures[key]; len(queued) > 0 {
+			failure = &queued[0]
+			s.failures[key] = queued[1:]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if failure != nil {
+		if failure.After {
+			s.route(httptest.NewRecorder(), r, path)
+		}
+		fail(w, *failure)
+		return
+	}
+	s.route(w, r, path)
+}
+
+// route serves the request for path.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "oauth2/authorize" {
+		s.authorize(w, r)
+		return
+	}
+	if path == "oauth2/token" {
+		s.token(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, Failure{Status: 401, Code: CodeInvalidToken, Message: "Access token is invalid"})
+		return
+	}
+	if !s.allow(w, path) {
+		return
+	}
+	// The handlers below use the fixtures, which UpdateFixtures may change.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) >= 4 && parts[1] == "accounts" && parts[3] == "orders":
+		s.orders(w, r, parts[2], parts[4:])
+	case r.Method != "GET":
+		writeError(w, Failure{Status: 404, Code: CodeInvalidEndpoint, Message: "Invalid endpoint"})
+	case path == "v1/time":
+		writeJSON(w, 200, map[string]interface{}{"time": time.Now()})
+	case path == "v1/accounts":
+		writeJSON(w, 200, api.AccountsResponse{Accounts: s.Fixtures.Accounts, UserId: s.Fixtures.UserId})
+	case len(parts) == 4 && parts[1] == "accounts":
+		s.account(w, r, parts[2], parts[3])
+	case path == "v1/symbols":
+		s.symbols(w, r)
+	case path == "v1/symbols/search":
+		s.search(w, r)
+	case path == "v1/notifications":
+		s.streamPort(w, r, true)
+	case path == "v1/markets/quotes" && r.URL.Query().Get("stream") == "true":
+		s.streamPort(w, r, false)
+	case path == "v1/markets/quotes":
+		s.quotes(w, r)
+	case len(parts) == 4 && parts[1] == "markets" && parts[2] == "candles":
+		s.candles(w, r, parts[3])
+	default:
+		writeError(w, Failure{Status: 404, Code: CodeInvalidEndpoint, Message: "Invalid endpoint"})
+	}
+}
+
+// authorize grants access without asking and redirects back with a one-time
+// code, or with the tokens in the fragment for the implicit flow.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect := q.Get("redirect_uri")
+	if redirect == "" || q.Get("client_id") == "" {
+		writeJSON(w, 400, map[string]string{"error": "invalid_request"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch q.Get("response_type") {
+	case "code":
+		code := newToken()
+		s.codes[code] = true
+		back := url.Values{"code": {code}, "state": {q.Get("state")}}
+		http.Redirect(w, r, redirect+"?"+back.Encode(), http.StatusFound)
+	case "token":
+		session := s.issue()
+		back := url.Values{
+			"access_token":  {session.AccessToken},
+			"api_server":    {session.ApiServer},
+			"expires_in":    {strconv.Itoa(session.ExpiresIn)},
+			"refresh_token": {session.RefreshToken},
+			"token_type":    {session.TokenType},
+			"state":         {q.Get("state")},
+		}
+		http.Redirect(w, r, redirect+"#"+back.Encode(), http.StatusFound)
+	default:
+		writeJSON(w, 400, map[string]string{"error": "unsupported_response_type"})
+	}
+}
+
+// token redeems the current refresh token, or a code handed out by
+// authorize, for a new access token and a new refresh token. Anything else
+// is rejected with 400.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case q.Get("grant_type") == "refresh_token" && q.Get("refresh_token") == s.refreshToken:
+	case q.Get("grant_type") == "authorization_code" && s.codes[q.Get("code")]:
+		delete(s.codes, q.Get("code"))
+	default:
+		writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, 200, s.issue())
+}
+
+// issue hands out a new access token and rotates the refresh token.
+func (s *Server) issue() *api.Session {
+	access := newToken()
+	s.accessTokens[access] = time.Now().Add(s.TokenLifetime)
+	s.refreshToken = newToken()
+	return &api.Session{
+		AccessToken:  access,
+		ApiServer:    s.URL + "/",
+		ExpiresIn:    int(s.TokenLifetime / time.Second),
+		RefreshToken: s.refreshToken,
+		TokenType:    "Bearer",
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt, ok := s.accessTokens[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// allow counts the request against the budget of its category and sets the
+// rate-limit headers. It answers 429 and returns false once the budget is spent.
+func (s *Server) allow(w http.ResponseWriter, path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.RateLimit <= 0 {
+		return true
+	}
+	category := api.AccountCalls
+	if strings.HasPrefix(path, "v1/markets") || strings.HasPrefix(path, "v1/symbols") {
+		category = api.MarketDataCalls
+	}
+	now := time.Now()
+	b := s.budgets[category]
+	if b == nil || !now.Before(b.reset) {
+		// X-RateLimit-Reset has a resolution of seconds, so windows end on one.
+		reset := now.Add(s.RateWindow).Truncate(time.Second)
+		if !reset.After(now) {
+			reset = reset.Add(time.Second)
+		}
+		b = &budget{remaining: s.RateLimit, reset: reset}
+		s.budgets[category] = b
+	}
+	header := http.Header{}
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(b.reset.Unix(), 10))
+	if b.remaining <= 0 {
+		header.Set("X-RateLimit-Remaining", "0")
+		writeError(w, Failure{Status: 429, Code: CodeRateLimited, Message: "Rate limit exceeded", Header: header})
+		return false
+	}
+	b.remaining--
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(b.remaining))
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	return true
+}
+
+// timeRange parses the startTime and endTime parameters. A missing bound is open.
+func timeRange(r *http.Request) (start, end time.Time, err error) {
+	q := r.URL.Query()
+	if v := q.Get("startTime"); v != "" {
+		if start, err = time.Parse(time.RFC3339, v); err != nil {
+			return
+		}
+	}
+	if v := q.Get("endTime"); v != "" {
+		end, err = time.Parse(time.RFC3339, v)
+	}
+	return
+}
+
+func inRange(t, start, end time.Time) bool {
+	return !t.Before(start) && (end.IsZero() || t.Before(end))
+}
+
+// period parses the range of an account history request, which may span at
+// most maxDays. It answers 400 and returns false if the range is missing or
+// too long.
+func period(w http.ResponseWriter, r *http.Request, maxDays int) (start, end time.Time, ok bool) {
+	start, end, err := timeRange(r)
+	switch {
+	case err != nil:
+	case start.IsZero() || end.IsZero():
+		err = fmt.Errorf("startTime and endTime are required")
+	case end.Sub(start) > time.Duration(maxDays)*24*time.Hour:
+		err = fmt.Errorf("range is longer than %v days", maxDays)
+	}
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// inPeriod reports whether t is in a period of account history. Unlike
+// other ranges it includes its end, so an event on the boundary of two
+// consecutive periods is listed by both.
+func inPeriod(t, start, end time.Time) bool {
+	return !t.Before(start) && !t.After(end)
+}
+
+// hasAccount answers 404 and returns false if there is no account number.
+func (s *Server) hasAccount(w http.ResponseWriter, number string) bool {
+	for _, a := range s.Fixtures.Accounts {
+		if a.Number == number {
+			return true
+		}
+	}
+	writeError(w, Failure{Status: 404, Code: CodeAccountNotFound, Message: "Account number not found"})
+	return false
+}
+
+func (s *Server) account(w http.ResponseWriter, r *http.Request, number, resource string) {
+	if !s.hasAccount(w, number) {
+		return
+	}
+	switch resource {
+	case "positions":
+		writeJSON(w, 200, api.PositionsResponse{Positions: s.Fixtures.Positions[number]})
+	case "balances":
+		writeJSON(w, 200, s.Fixtures.Balances[number])
+	case "activities":
+		start, end, ok := period(w, r, 31)
+		if !ok {
+			return
+		}
+		result := api.ActivitiesResponse{Activities: []api.Activity{}}
+		for _, a := range s.Fixtures.Activities[number] {
+			if inPeriod(a.TransactionDate.Time, start, end) {
+				result.Activities = append(result.Activities, a)
+			}
+		}
+		writeJSON(w, 200, result)
+	case "executions":
+		start, end, ok := period(w, r, 30)
+		if !ok {
+			return
+		}
+		result := api.ExecutionsResponse{Executions: []api.Execution{}}
+		for _, e := range s.Fixtures.Executions[number] {
+			if inPeriod(e.Timestamp.Time, start, end) {
+				result.Executions = append(result.Executions, e)
+			}
+		}
+		writeJSON(w, 200, result)
+	default:
+		writeError(w, Failure{Status: 404, Code: CodeInvalidEndpoint, Message: "Invalid endpoint"})
+	}
+}
+
+// ids parses a comma separated ids parameter.
+func ids(value string) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", v)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+func (s *Server) symbols(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	result := api.SymbolsResponse{Symbols: []api.Symbol{}}
+	if names := q.Get("names"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			for _, sym := range s.Fixtures.Symbols {
+				if strings.EqualFold(sym.Symbol, name) {
+					result.Symbols = append(result.Symbols, sym)
+				}
+			}
+		}
+		writeJSON(w, 200, result)
+		return
+	}
+	wanted, err := ids(q.Get("ids"))
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return
+	}
+	for _, id := range wanted {
+		for _, sym := range s.Fixtures.Symbols {
+			if sym.SymbolId == id {
+				result.Symbols = append(result.Symbols, sym)
+			}
+		}
+	}
+	writeJSON(w, 200, result)
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := strings.ToUpper(q.Get("prefix"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	result := api.SymbolSearchResponse{Symbols: []api.EquitySymbol{}}
+	for _, sym := range s.Fixtures.Symbols {
+		if !strings.HasPrefix(strings.ToUpper(sym.Symbol), prefix) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		result.Symbols = append(result.Symbols, api.EquitySymbol{
+			Symbol:          sym.Symbol,
+			SymbolId:        sym.SymbolId,
+			Description:     sym.Description,
+			SecurityType:    sym.SecurityType,
+			ListingExchange: sym.ListingExchange,
+			IsTradable:      sym.IsTradable,
+			IsQuotable:      sym.IsQuotable,
+			Currency:        sym.Currency,
+		})
+	}
+	writeJSON(w, 200, result)
+}
+
+func (s *Server) quotes(w http.ResponseWriter, r *http.Request) {
+	wanted, err := ids(r.URL.Query().Get("ids"))
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return
+	}
+	result := api.QuotesResponse{Quotes: []api.Quote{}}
+	for _, id := range wanted {
+		for _, quote := range s.Fixtures.Quotes {
+			if quote.SymbolId == id {
+				result.Quotes = append(result.Quotes, quote)
+			}
+		}
+	}
+	writeJSON(w, 200, result)
+}
+
+func (s *Server) candles(w http.ResponseWriter, r *http.Request, symbolId string) {
+	id, err := strconv.Atoi(symbolId)
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: "invalid symbol id"})
+		return
+	}
+	start, end, err := timeRange(r)
+	if err != nil {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+		return
+	}
+	result := api.CandlesResponse{Candles: []api.Candle{}}
+	for _, candle := range s.Fixtures.Candles[id] {
+		if inRange(candle.Start.Time, start, end) && len(result.Candles) < maxCandles {
+			result.Candles = append(result.Candles, candle)
+		}
+	}
+	writeJSON(w, 200, result)
+}
@@ -0,0 +1,292 @@
+package questradetest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dk1027/go-questrade-api/api"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// streamConn is the server end of a quote or notification stream: a
+// WebSocket connection that has authenticated with a valid access token.
+type streamConn struct {
+	conn  net.Conn
+	br    *bufio.Reader
+	wmu   sync.Mutex
+	ids   []int
+	timer *time.Timer
+	// notifications is set on notification streams, which get order and
+	// execution events instead of quotes.
+	notifications bool
+}
+
+// notification is the message a notification stream sends for events in one account.
+type notification struct {
+	AccountNumber string          `json:"accountNumber"`
+	Orders        []api.Order     `json:"orders,omitempty"`
+	Executions    []api.Execution `json:"executions,omitempty"`
+}
+
+// FailStreamAuth makes the next stream authentication fail as if the access
+// token were invalid, whatever token the client sends.
+func (s *Server) FailStreamAuth() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamAuthFailures++
+}
+
+// DropStreams cuts every open stream connection without a close frame, the
+// way a network failure would.
+func (s *Server) DropStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sc := range s.streams {
+		sc.conn.Close()
+	}
+}
+
+// Streams returns how many stream connections are open and authenticated.
+func (s *Server) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// PushQuotes sends quotes to every open stream subscribed to their symbols.
+func (s *Server) PushQuotes(quotes ...api.Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sc := range s.streams {
+		sc.send(quotes)
+	}
+}
+
+// PushOrders stores orders in account, replacing those with the same id, and
+// sends them to every open notification stream.
+func (s *Server) PushOrders(account string, orders ...api.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range orders {
+		if existing := s.findOrder(account, o.Id); existing != nil {
+			*existing = o
+		} else {
+			s.Fixtures.Orders[account] = append(s.Fixtures.Orders[account], o)
+		}
+	}
+	s.notify(account, orders, nil)
+}
+
+// PushExecutions stores executions in account and sends them to every open
+// notification stream.
+func (s *Server) PushExecutions(account string, executions ...api.Execution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fixtures.Executions == nil {
+		s.Fixtures.Executions = map[string][]api.Execution{}
+	}
+	s.Fixtures.Executions[account] = append(s.Fixtures.Executions[account], executions...)
+	s.notify(account, nil, executions)
+}
+
+// notify sends events of account to the notification streams. It is called
+// with s.mu held.
+func (s *Server) notify(account string, orders []api.Order, executions []api.Execution) {
+	for sc := range s.streams {
+		if sc.notifications {
+			sc.writeJSON(notification{AccountNumber: account, Orders: orders, Executions: executions})
+		}
+	}
+}
+
+// streamPort answers a quotes request with stream=true, or a notifications
+// request: it opens a listener for the subscription and returns its port, as
+// the real API does. It is called with s.mu held.
+func (s *Server) streamPort(w http.ResponseWriter, r *http.Request, notifications bool) {
+	if r.URL.Query().Get("mode") != "WebSocket" {
+		writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: "only WebSocket streaming is supported"})
+		return
+	}
+	var wanted []int
+	if !notifications {
+		var err error
+		if wanted, err = ids(r.URL.Query().Get("ids")); err != nil {
+			writeError(w, Failure{Status: 400, Code: CodeInvalidArgument, Message: err.Error()})
+			return
+		}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		writeError(w, Failure{Status: 500, Message: err.Error()})
+		return
+	}
+	s.listeners = append(s.listeners, listener)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.stream(&streamConn{conn: conn, br: bufio.NewReader(conn), ids: wanted, notifications: notifications})
+		}
+	}()
+	writeJSON(w, 200, map[string]int{"streamPort": listener.Addr().(*net.TCPAddr).Port})
+}
+
+// stream upgrades the connection of sc, checks the access token sent as the
+// first message and sends the current quotes sc is subscribed to. The
+// connection is dropped when the access token expires.
+func (s *Server) stream(sc *streamConn) {
+	conn := sc.conn
+	defer conn.Close()
+	req, err := http.ReadRequest(sc.br)
+	if err != nil {
+		return
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") || key == "" {
+		fmt.Fprint(conn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+
+	opcode, token, err := sc.readFrame()
+	if err != nil || opcode != wsText {
+		return
+	}
+	s.mu.Lock()
+	expiresAt, ok := s.accessTokens[string(token)]
+	ok = ok && time.Now().Before(expiresAt)
+	if s.streamAuthFailures > 0 {
+		s.streamAuthFailures--
+		ok = false
+	}
+	if !ok {
+		s.mu.Unlock()
+		sc.writeJSON(map[string]interface{}{"success": false, "code": CodeInvalidToken, "message": "Access token is invalid"})
+		sc.writeFrame(wsClose, []byte{0x03, 0xe8})
+		return
+	}
+	sc.writeJSON(map[string]bool{"success": true})
+	sc.send(s.Fixtures.Quotes)
+	sc.timer = time.AfterFunc(time.Until(expiresAt), func() { conn.Close() })
+	s.streams[sc] = true
+	s.mu.Unlock()
+
+	defer func() {
+		sc.timer.Stop()
+		s.mu.Lock()
+		delete(s.streams, sc)
+		s.mu.Unlock()
+	}()
+	// Nothing is expected from the client anymore; read until it goes away.
+	for {
+		opcode, payload, err := sc.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsClose:
+			sc.writeFrame(wsClose, payload)
+			return
+		case wsPing:
+			sc.writeFrame(wsPong, payload)
+		}
+	}
+}
+
+// send writes the quotes among quotes that sc is subscribed to, if any.
+func (sc *streamConn) send(quotes []api.Quote) {
+	result := api.QuotesResponse{Quotes: []api.Quote{}}
+	for _, q := range quotes {
+		for _, id := range sc.ids {
+			if q.SymbolId == id {
+				result.Quotes = append(result.Quotes, q)
+			}
+		}
+	}
+	if len(result.Quotes) > 0 {
+		sc.writeJSON(result)
+	}
+}
+
+func (sc *streamConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sc.writeFrame(wsText, data)
+}
+
+// writeFrame sends one unmasked frame, as servers must.
+func (sc *streamConn) writeFrame(opcode byte, payload []byte) error {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(n))
+		header = append(append(header, 127), ext...)
+	}
+	_, err := sc.conn.Write(append(header, payload...))
+	return err
+}
+
+// readFrame reads one masked client frame. Streams only carry short control
+// and token messages, so fragmentation is not supported.
+func (sc *streamConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(sc.br, head[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(sc.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		return 0, nil, fmt.Errorf("client frame too large")
+	}
+	var mask [4]byte
+	if head[1]&0x80 != 0 {
+		if _, err := io.ReadFull(sc.br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(sc.br, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return head[0] & 0x0f, payload, nil
+}